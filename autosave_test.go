@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Performs a complete run, draining its events
+func runToEnd(p gol.Params) {
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	for range events {
+	}
+}
+
+// TestSaveEveryTurns saves every 10 turns of a 100 turn run keeping the last 3, and checks the images left behind
+// and the manifest listing them.
+func TestSaveEveryTurns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autosave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, FlipEvents: gol.FlipNone,
+		OutputDir: dir, SaveEveryTurns: 10, KeepSaves: 3}
+	runToEnd(p)
+
+	entries, err := gol.ReadManifest(filepath.Join(dir, "64x64.manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []gol.ManifestEntry{
+		{Turn: 80, File: "64x64x80.pgm", Kind: gol.PeriodicSave},
+		{Turn: 90, File: "64x64x90.pgm", Kind: gol.PeriodicSave},
+		{Turn: 100, File: "64x64x100.pgm", Kind: gol.PeriodicSave},
+		{Turn: 100, File: "64x64x100.pgm", Kind: gol.FinalSave},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %v manifest entries, got %v", len(expected), entries)
+	}
+	for i, entry := range entries {
+		if entry.Turn != expected[i].Turn || entry.File != expected[i].File || entry.Kind != expected[i].Kind {
+			t.Errorf("expected entry %v to be %+v, got %+v", i, expected[i], entry)
+		}
+		if i > 0 && entry.Time.Before(entries[i-1].Time) {
+			t.Errorf("expected entries to be in the order they were written, got %v after %v", entry.Time,
+				entries[i-1].Time)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	if fmt.Sprint(names) != "[64x64.manifest.json 64x64x100.pgm 64x64x80.pgm 64x64x90.pgm]" {
+		t.Errorf("expected only the kept images and the manifest to be left, got %v", names)
+	}
+	p.Turns = 100
+	assertEqualBoard(t, util.ReadAliveCells(filepath.Join(dir, "64x64x100.pgm"), 64, 64),
+		util.ReadAliveCells("check/images/64x64x100.pgm", 64, 64), p)
+}
+
+// TestSaveEvery saves every 50ms of a run limited to 100 turns per second, and checks that images were written
+// along the way.
+func TestSaveEvery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autosave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 50, Threads: 2, TurnsPerSecond: 100,
+		OutputDir: dir, SaveEvery: 50 * time.Millisecond}
+	runToEnd(p)
+
+	entries, err := gol.ReadManifest(filepath.Join(dir, "16x16.manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodic := 0
+	for _, entry := range entries {
+		if entry.Kind == gol.PeriodicSave {
+			periodic++
+			if entry.Turn <= 0 || entry.Turn > p.Turns {
+				t.Errorf("expected periodic saves to be of turns 1 to %v, got %v", p.Turns, entry.Turn)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, entry.File)); err != nil {
+			t.Error(err)
+		}
+	}
+	if periodic < 3 {
+		t.Errorf("expected at least 3 periodic saves in half a second, got %v", entries)
+	}
+	if last := entries[len(entries)-1]; last.Kind != gol.FinalSave || last.Turn != p.Turns {
+		t.Errorf("expected the final image to be listed last, got %+v", last)
+	}
+}
+
+// TestSaveEveryTurn saves every turn of a busy 512x512 run, faster than images can be written, and checks that turns
+// wait for the saves rather than queueing up copies of the world, so that memory stays bounded, and that the images
+// kept are right.
+func TestSaveEveryTurn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autosave")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 1000, Threads: 8, FlipEvents: gol.FlipNone,
+		OutputDir: dir, SaveEveryTurns: 1, KeepSaves: 2}
+	done := make(chan bool)
+	peak := make(chan uint64)
+	go func() { // Samples the heap until the run is over
+		var stats runtime.MemStats
+		var max uint64
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > max {
+				max = stats.HeapAlloc
+			}
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}()
+	runToEnd(p)
+	close(done)
+	if max := <-peak; max > 256<<20 {
+		t.Errorf("expected the heap to stay below 256MiB, got %vMiB", max>>20)
+	}
+
+	entries, err := gol.ReadManifest(filepath.Join(dir, "512x512.manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, entry := range entries {
+		if i > 0 && entry.Turn < entries[i-1].Turn {
+			t.Errorf("expected saves in the order of their turns, got %v after %v", entry.Turn, entries[i-1].Turn)
+		}
+	}
+	last := entries[len(entries)-1]
+	if last.Kind != gol.FinalSave || last.Turn != p.Turns {
+		t.Fatalf("expected the final image to be listed last, got %+v", last)
+	}
+	simulator, err := gol.NewSimulator(p, util.ReadAliveCells("images/512x512.pgm", 512, 512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer simulator.Close()
+	for _, entry := range entries[:len(entries)-1] {
+		err = simulator.Step(entry.Turn - simulator.CompletedTurns())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Turns = entry.Turn
+		assertEqualBoard(t, util.ReadAliveCells(filepath.Join(dir, entry.File), 512, 512),
+			simulator.Snapshot().AliveCells(), p)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Returns the alive cells of a world built up from the CellFlipped, CellsFlipped and ViewMoved events received
+func applyFlips(events []gol.Event, p gol.Params) []util.Cell {
+	world := make([][]bool, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]bool, p.ImageWidth)
+	}
+	for _, event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			world[e.Cell.Y][e.Cell.X] = !world[e.Cell.Y][e.Cell.X]
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				world[cell.Y][cell.X] = !world[cell.Y][cell.X]
+			}
+		case gol.ViewMoved:
+			for y := range world {
+				world[y] = make([]bool, p.ImageWidth)
+			}
+			for _, cell := range e.Alive {
+				world[cell.Y][cell.X] = true
+			}
+		}
+	}
+	var alive []util.Cell
+	for y, row := range world {
+		for x, cell := range row {
+			if cell {
+				alive = append(alive, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return alive
+}
+
+// Receives every event from a subscription in the background, returning a function that waits for them all
+func collect(subscription *gol.Subscription) func() []gol.Event {
+	var events []gol.Event
+	done := make(chan bool)
+	go func() {
+		for event := range subscription.Events {
+			events = append(events, event)
+		}
+		close(done)
+	}()
+	return func() []gol.Event {
+		<-done
+		return events
+	}
+}
+
+// TestBus subscribes three consumers to a 16x16 run over 100 turns, and checks that each receives the events it
+// asked for: every event, only TurnComplete events, and the flips coalesced into one CellsFlipped event per turn.
+func TestBus(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4}
+	bus := gol.NewBus()
+	all := collect(bus.Subscribe(gol.SubscribeOptions{Buffer: 10}))
+	turns := collect(bus.Subscribe(gol.SubscribeOptions{Filter: gol.EventsOfType(gol.TurnComplete{})}))
+	coalesced := collect(bus.Subscribe(gol.SubscribeOptions{Policy: gol.Coalesce, Buffer: 10}))
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	bus.Forward(events)
+
+	expected := util.ReadAliveCells("check/images/16x16x100.pgm", 16, 16)
+	allEvents := all()
+	assertEqualBoard(t, applyFlips(allEvents, p), expected, p)
+	if state, ok := allEvents[len(allEvents)-1].(gol.StateChange); !ok || state.NewState != gol.Quitting {
+		t.Errorf("expected the last event to be the Quitting state change, got %v", allEvents[len(allEvents)-1])
+	}
+
+	turnEvents := turns() // Including the one sent once the image has been loaded
+	if len(turnEvents) != p.Turns+1 {
+		t.Errorf("expected %v TurnComplete events, got %v", p.Turns+1, len(turnEvents))
+	}
+	for i, event := range turnEvents {
+		if turn, ok := event.(gol.TurnComplete); !ok || turn.CompletedTurns != i {
+			t.Fatalf("expected TurnComplete for turn %v, got %#v", i, event)
+		}
+	}
+
+	coalescedEvents := coalesced()
+	assertEqualBoard(t, applyFlips(coalescedEvents, p), expected, p)
+	flips := 0
+	for _, event := range coalescedEvents {
+		switch event.(type) {
+		case gol.CellFlipped:
+			t.Fatal("expected CellFlipped events to be coalesced")
+		case gol.CellsFlipped:
+			flips++
+		}
+	}
+	if flips > p.Turns+1 {
+		t.Errorf("expected at most one CellsFlipped event per turn, got %v", flips)
+	}
+}
+
+// TestBusCoalesceBatches checks that flips reported in batches are merged into the flips of their turn, along with
+// single flips, and that a cell flipped twice in a turn is left out.
+func TestBusCoalesceBatches(t *testing.T) {
+	bus := gol.NewBus()
+	coalesced := collect(bus.Subscribe(gol.SubscribeOptions{Policy: gol.Coalesce, Buffer: 10}))
+	a, b, c := util.Cell{X: 1, Y: 0}, util.Cell{X: 0, Y: 2}, util.Cell{X: 3, Y: 1}
+	for _, event := range []gol.Event{
+		gol.CellsFlipped{CompletedTurns: 1, Cells: []util.Cell{b, a}},
+		gol.CellFlipped{CompletedTurns: 1, Cell: b},
+		gol.CellsFlipped{CompletedTurns: 1, Cells: []util.Cell{c}},
+		gol.TurnComplete{CompletedTurns: 1},
+		gol.CellsFlipped{CompletedTurns: 2, Cells: []util.Cell{a}},
+	} {
+		bus.Publish(event)
+	}
+	bus.Close()
+
+	expected := []gol.Event{
+		gol.CellsFlipped{CompletedTurns: 1, Cells: []util.Cell{a, c}},
+		gol.TurnComplete{CompletedTurns: 1},
+		gol.CellsFlipped{CompletedTurns: 2, Cells: []util.Cell{a}},
+	}
+	received := coalesced()
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %#v, got %#v", expected, received)
+	}
+}
+
+// TestBusSlowConsumer checks that a subscriber that drops its oldest events does not stall a run, even if nothing
+// receives from it until the run has finished, and that it is left with the most recent events.
+func TestBusSlowConsumer(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4}
+	bus := gol.NewBus()
+	slow := bus.Subscribe(gol.SubscribeOptions{Policy: gol.DropOldest, Buffer: 5})
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	bus.Forward(events)
+
+	var received []gol.Event
+	for event := range slow.Events {
+		received = append(received, event)
+	}
+	if len(received) > 6 { // The buffer plus the event that was being delivered when the consumer stopped keeping up
+		t.Errorf("expected at most 6 events, got %v", len(received))
+	}
+	if state, ok := received[len(received)-1].(gol.StateChange); !ok || state.NewState != gol.Quitting {
+		t.Errorf("expected the last event to be the Quitting state change, got %v", received[len(received)-1])
+	}
+}
+
+// TestBusCancel cancels a subscription part way through a run and checks that the other subscribers are unaffected.
+func TestBusCancel(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4}
+	bus := gol.NewBus()
+	cancelled := bus.Subscribe(gol.SubscribeOptions{})
+	all := collect(bus.Subscribe(gol.SubscribeOptions{}))
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bus.Forward(events)
+	}()
+
+	for event := range cancelled.Events {
+		if turn, ok := event.(gol.TurnComplete); ok && turn.CompletedTurns == 10 {
+			cancelled.Cancel()
+		}
+	}
+	wg.Wait()
+	expected := util.ReadAliveCells("check/images/16x16x100.pgm", 16, 16)
+	assertEqualBoard(t, applyFlips(all(), p), expected, p)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Returns the rows of an image along with the header of a world file, choosing the format from its extension
+// PGM images have no header of their own, so theirs is filled in from the flags
+func readImage(path string, header util.WorldHeader) (util.WorldHeader, [][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return header, nil, err
+	}
+	defer file.Close()
+	switch filepath.Ext(path) {
+	case ".world":
+		return util.ReadWorld(file)
+	case ".pgm":
+		rows, err := util.ReadPGM(file)
+		if err != nil {
+			return header, nil, err
+		}
+		header.Width, header.Height = len(rows[0]), len(rows)
+		return header, rows, nil
+	}
+	return header, nil, fmt.Errorf("%v: expected a .pgm or .world file", path)
+}
+
+// Writes the rows of an image, choosing the format from its extension
+func writeImage(path string, header util.WorldHeader, rows [][]byte) error {
+	var write func(file *os.File) error
+	switch filepath.Ext(path) {
+	case ".world":
+		write = func(file *os.File) error { return util.WriteWorld(file, header, rows) }
+	case ".pgm":
+		write = func(file *os.File) error { return util.WritePGM(file, rows) }
+	default:
+		return fmt.Errorf("%v: expected a .pgm or .world file", path)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// main converts an image between the PGM and compressed world formats, for example with
+// 'go run ./cmd/convert images/512x512.pgm 512x512.world'
+func main() {
+	var header util.WorldHeader
+	flag.IntVar(
+		&header.Turn,
+		"turn",
+		0,
+		"Specify the turn recorded in world files converted from PGM. Defaults to 0.")
+
+	flag.StringVar(
+		&header.Rule,
+		"rule",
+		"B3/S23",
+		"Specify the rule recorded in world files converted from PGM. Defaults to B3/S23.")
+
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: convert [flags] input output")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	header, rows, err := readImage(flag.Arg(0), header)
+	if err == nil {
+		err = writeImage(flag.Arg(1), header, rows)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Converted %vx%v world at turn %v from %v to %v\n", header.Width, header.Height, header.Turn,
+		flag.Arg(0), flag.Arg(1))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Sends a command and returns its reply, failing the test if the run ends first
+func sendCommand(t *testing.T, commands chan<- gol.Command, events <-chan gol.Event, command gol.Command) gol.Reply {
+	return sendCommandReplying(t, commands, events, command, make(chan gol.Reply, 1))
+}
+
+// Sends a command asking for its reply on the given channel and returns it, failing the test if the run ends first
+func sendCommandReplying(t *testing.T, commands chan<- gol.Command, events <-chan gol.Event, command gol.Command,
+	replies chan gol.Reply) gol.Reply {
+	command.Reply = replies
+	for sent := false; !sent; { // Keep draining events until the command has been received
+		select {
+		case commands <- command:
+			sent = true
+		case _, ok := <-events:
+			if !ok {
+				t.Fatalf("the run ended before command %v was sent", command.Type)
+			}
+		}
+	}
+	for {
+		select {
+		case reply := <-replies:
+			return reply
+		case _, ok := <-events:
+			if !ok {
+				t.Fatalf("the run ended before command %v was replied to", command.Type)
+			}
+		}
+	}
+}
+
+// TestCommands drives a run with commands instead of key presses, checking the reply to each of them.
+func TestCommands(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, Threads: 4}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunCommands(context.Background(), p, events, commands)
+	}()
+
+	paused := sendCommand(t, commands, events, gol.Command{Type: gol.PauseCommand})
+	if paused.Err != nil {
+		t.Fatal(paused.Err)
+	}
+	before := sendCommand(t, commands, events, gol.Command{Type: gol.SnapshotCommand}).Snapshot
+	if before.CompletedTurns != paused.CompletedTurns {
+		t.Errorf("expected the snapshot to be taken at turn %v, got %v", paused.CompletedTurns, before.CompletedTurns)
+	}
+
+	// Replace the world with a blinker, which is vertical after an odd number of turns
+	var edits []gol.CellEdit
+	for _, cell := range before.AliveCells() {
+		edits = append(edits, gol.CellEdit{Cell: cell, Alive: false})
+	}
+	for x := 4; x <= 6; x++ {
+		edits = append(edits, gol.CellEdit{Cell: util.Cell{X: x, Y: 5}, Alive: true})
+	}
+	sendCommand(t, commands, events, gol.Command{Type: gol.EditCellsCommand, Edits: edits})
+	stepped := sendCommand(t, commands, events, gol.Command{Type: gol.StepCommand})
+	if stepped.Err != nil {
+		t.Fatal(stepped.Err)
+	}
+
+	var after gol.Snapshot
+	for after.CompletedTurns != before.CompletedTurns+1 {
+		after = sendCommand(t, commands, events, gol.Command{Type: gol.SnapshotCommand}).Snapshot
+	}
+	vertical := []util.Cell{{X: 5, Y: 4}, {X: 5, Y: 5}, {X: 5, Y: 6}}
+	assertEqualBoard(t, after.AliveCells(), vertical, p)
+
+	saved := sendCommand(t, commands, events, gol.Command{Type: gol.SaveCommand})
+	if saved.Err != nil || saved.Filename != fmt.Sprintf("16x16x%v", after.CompletedTurns) {
+		t.Errorf("expected a save to 16x16x%v, got %v (%v)", after.CompletedTurns, saved.Filename, saved.Err)
+	}
+	sendCommand(t, commands, events, gol.Command{Type: gol.ResumeCommand})
+	if reply := sendCommand(t, commands, events, gol.Command{Type: gol.StepCommand}); reply.Err != gol.ErrNotPaused {
+		t.Errorf("expected stepping while running to fail with %v, got %v", gol.ErrNotPaused, reply.Err)
+	}
+
+	sendCommand(t, commands, events, gol.Command{Type: gol.QuitCommand})
+	imageOutput := false
+	for event := range events {
+		if _, ok := event.(gol.ImageOutputComplete); ok {
+			imageOutput = true
+		}
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if !imageOutput {
+		t.Error("expected quitting to write the final image")
+	}
+}
+
+// TestShutdownCommand checks that shutting down stops a run without writing the final image.
+func TestShutdownCommand(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, Threads: 2}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 1)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunCommands(context.Background(), p, events, commands)
+	}()
+
+	commands <- gol.Command{Type: gol.ShutdownCommand}
+	var last gol.Event
+	for event := range events {
+		if _, ok := event.(gol.ImageOutputComplete); ok {
+			t.Error("expected shutting down not to write the final image")
+		}
+		last = event
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if state, ok := last.(gol.StateChange); !ok || state.NewState != gol.Quitting {
+		t.Errorf("expected the last event to be the Quitting state change, got %v", last)
+	}
+}
+
+// TestUnbufferedReply sends commands whose Reply channel is not buffered, checking that every one is replied to
+// rather than the replies being dropped while nothing is receiving them yet.
+func TestUnbufferedReply(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4, FlipEvents: gol.FlipNone}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunCommands(context.Background(), p, events, commands)
+	}()
+
+	replies := make(chan gol.Reply)
+	for _, commandType := range []gol.CommandType{gol.SaveCommand, gol.PauseCommand, gol.SnapshotCommand,
+		gol.StepCommand, gol.SaveCommand, gol.ResumeCommand, gol.SetSpeedCommand} {
+		reply := sendCommandReplying(t, commands, events, gol.Command{Type: commandType}, replies)
+		if reply.Err != nil {
+			t.Fatal(reply.Err)
+		}
+	}
+	sendCommandReplying(t, commands, events, gol.Command{Type: gol.QuitCommand}, replies)
+	for range events {
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// Fails the test if the number of goroutines does not drop back to at most expected within a second
+func assertNoLeakedGoroutines(t *testing.T, expected int) {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > expected {
+		if time.Now().After(deadline) {
+			buffer := make([]byte, 1<<20)
+			t.Fatalf("expected at most %v goroutines, got %v\n%s", expected, runtime.NumGoroutine(),
+				buffer[:runtime.Stack(buffer, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRunContextCancel cancels a long run part way through and checks that every goroutine it started has stopped.
+func TestRunContextCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100000000, Threads: 8}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunContext(ctx, p, events, keyPresses)
+	}()
+
+	for event := range events {
+		if turn, ok := event.(gol.TurnComplete); ok && turn.CompletedTurns == 5 {
+			cancel()
+		}
+	}
+	if err := <-result; err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	assertNoLeakedGoroutines(t, before)
+}
+
+// TestRunContextCancelPaused cancels a paused run, without anything reading events, and checks that it still stops.
+func TestRunContextCancelPaused(t *testing.T) {
+	before := runtime.NumGoroutine()
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 1)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunContext(ctx, p, events, keyPresses)
+	}()
+
+	keyPresses <- 'p'
+	for event := range events {
+		if state, ok := event.(gol.StateChange); ok && state.NewState == gol.Paused {
+			break
+		}
+	}
+	cancel()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Fatalf("expected %v, got %v", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunContext did not return within a second of being cancelled")
+	}
+	assertNoLeakedGoroutines(t, before)
+}
+
+// TestRunContextMissingImage checks that a missing input image is returned as an error instead of panicking.
+func TestRunContextMissingImage(t *testing.T) {
+	p := gol.Params{ImageWidth: 17, ImageHeight: 17, Turns: 1, Threads: 1}
+	events := make(chan gol.Event)
+	go func() {
+		for range events {
+		}
+	}()
+	err := gol.RunContext(context.Background(), p, events, nil)
+	if err == nil {
+		t.Fatal("expected an error for a missing image")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestEdit pauses a 16x16 run, replaces the whole world with a block and checks that the block survives until quitting.
+// The edit is followed by a CellsEdited rather than a TurnComplete, so that every TurnComplete is of a new turn.
+func TestEdit(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100000000, Threads: 4}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	cellEdits := make(chan []gol.CellEdit, 10)
+	result := make(chan error, 1)
+	go func() {
+		result <- gol.RunEditableContext(context.Background(), p, events, keyPresses, cellEdits)
+	}()
+
+	block := []util.Cell{{X: 5, Y: 5}, {X: 6, Y: 5}, {X: 5, Y: 6}, {X: 6, Y: 6}}
+	var edits []gol.CellEdit
+	for y := 0; y < p.ImageHeight; y++ {
+		for x := 0; x < p.ImageWidth; x++ {
+			cell := util.Cell{X: x, Y: y}
+			edits = append(edits, gol.CellEdit{Cell: cell, Alive: cell.X >= 5 && cell.X <= 6 && cell.Y >= 5 && cell.Y <= 6})
+		}
+	}
+
+	keyPresses <- 'p'
+	var final []util.Cell
+	lastTurn, edited := -1, false
+	for event := range events {
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			if e.CompletedTurns <= lastTurn {
+				t.Errorf("expected each TurnComplete to be of a new turn, got turn %v after %v", e.CompletedTurns,
+					lastTurn)
+			}
+			lastTurn = e.CompletedTurns
+		case gol.CellsEdited:
+			edited = true
+		case gol.StateChange:
+			switch e.NewState {
+			case gol.Paused:
+				cellEdits <- edits
+				keyPresses <- 'p'
+			case gol.Continuing:
+				keyPresses <- 'q'
+			}
+		case gol.FinalTurnComplete:
+			final = e.Alive
+		}
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if !edited {
+		t.Error("expected a CellsEdited event after the edit")
+	}
+	assertEqualBoard(t, final, block, p)
+}
+
+// TestReadRLE checks that the patterns shipped with the SDL editor parse to the right number of cells.
+func TestReadRLE(t *testing.T) {
+	patterns, err := util.ReadPatternLibrary("patterns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{
+		"Glider":                5,
+		"Gosper glider gun":     36,
+		"Lightweight spaceship": 9,
+		"R-pentomino":           5,
+	}
+	if len(patterns) != len(expected) {
+		t.Fatalf("expected %v patterns, got %v", len(expected), len(patterns))
+	}
+	for _, pattern := range patterns {
+		if len(pattern.Cells) != expected[pattern.Name] {
+			t.Errorf("expected %v to have %v cells, got %v", pattern.Name, expected[pattern.Name], len(pattern.Cells))
+		}
+	}
+
+	glider, err := util.ParseRLE(strings.NewReader("x = 3, y = 3\nbo$2bo$3o!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedGlider := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	assertEqualBoard(t, glider.Cells, expectedGlider, gol.Params{ImageWidth: 3, ImageHeight: 3})
+}
+
+// TestParseRLELimits checks that runs and cells that do not fit in the header are rejected, including counts long
+// enough to overflow, which must not wrap around to place cells before the start of the row.
+func TestParseRLELimits(t *testing.T) {
+	for name, test := range map[string]struct {
+		rle   string
+		cells int
+	}{
+		"fits":             {"x = 3, y = 3\n2$3o!", 3},
+		"fits a long run":  {"x = 12, y = 1\nb10ob!", 10},
+		"too wide":         {"x = 3, y = 3\n4o!", -1},
+		"too high":         {"x = 3, y = 3\n3$o!", -1},
+		"long run":         {"x = 3, y = 3\n1000000000o!", -1},
+		"overflowing skip": {"x = 3, y = 3\n18446744073709551615bo!", -1},
+		"overflowing rows": {"x = 3, y = 3\n18446744073709551615$o!", -1},
+		"single digit":     {"x = 3, y = 3\n5bo!", -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pattern, err := util.ParseRLE(strings.NewReader(test.rle))
+			if test.cells < 0 {
+				if err == nil {
+					t.Errorf("expected an error, got the cells %v", pattern.Cells)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pattern.Cells) != test.cells {
+				t.Errorf("expected %v cells, got %v", test.cells, len(pattern.Cells))
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/export"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestEventJSON checks the JSON encoding of every event type against its expected form, and that decoding it gives
+// back the same event.
+func TestEventJSON(t *testing.T) {
+	tests := []struct {
+		event   gol.Event
+		encoded string
+	}{
+		{gol.AliveCellsCount{CompletedTurns: 2, CellsCount: 30}, `{"type":"AliveCellsCount","turn":2,"cellsCount":30}`},
+		{gol.ImageOutputComplete{CompletedTurns: 3, Filename: "16x16x3"},
+			`{"type":"ImageOutputComplete","turn":3,"filename":"16x16x3"}`},
+		{gol.StateChange{CompletedTurns: 4, NewState: gol.Paused}, `{"type":"StateChange","turn":4,"state":"Paused"}`},
+		{gol.SpeedChange{CompletedTurns: 5, TurnsPerSecond: 0}, `{"type":"SpeedChange","turn":5,"turnsPerSecond":0}`},
+		{gol.CellFlipped{CompletedTurns: 6, Cell: util.Cell{X: 1, Y: 2}}, `{"type":"CellFlipped","turn":6,"cell":[1,2]}`},
+		{gol.CellsFlipped{CompletedTurns: 7, Cells: []util.Cell{{X: 1, Y: 2}, {X: 3, Y: 4}}},
+			`{"type":"CellsFlipped","turn":7,"cells":[[1,2],[3,4]]}`},
+		{gol.TurnComplete{CompletedTurns: 8}, `{"type":"TurnComplete","turn":8}`},
+		{gol.CellsEdited{CompletedTurns: 8}, `{"type":"CellsEdited","turn":8}`},
+		{gol.ViewMoved{CompletedTurns: 8, Origin: util.Cell{X: -3, Y: 4}, Alive: []util.Cell{{X: 1, Y: 2}}},
+			`{"type":"ViewMoved","turn":8,"origin":[-3,4],"alive":[[1,2]]}`},
+		{gol.FinalTurnComplete{CompletedTurns: 9, Alive: []util.Cell{{X: 0, Y: 5}}},
+			`{"type":"FinalTurnComplete","turn":9,"aliveCount":1,"alive":[[0,5]]}`},
+	}
+	for _, test := range tests {
+		encoded, err := json.Marshal(test.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(encoded) != test.encoded {
+			t.Errorf("expected %T to be encoded as %v, got %s", test.event, test.encoded, encoded)
+		}
+		decoded, err := gol.UnmarshalEvent(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, test.event) {
+			t.Errorf("expected %s to decode to %#v, got %#v", encoded, test.event, decoded)
+		}
+	}
+	for _, invalid := range []string{`{"type":"Unknown","turn":1}`, `{"type":"StateChange","turn":1}`,
+		`{"type":"StateChange","turn":1,"state":"Sleeping"}`, `not json`} {
+		if _, err := gol.UnmarshalEvent([]byte(invalid)); err == nil {
+			t.Errorf("expected %v to fail to decode", invalid)
+		}
+	}
+}
+
+// TestEventLog logs a 16x16 run over 100 turns and checks the entries read back from the log.
+func TestEventLog(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4}
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	var log bytes.Buffer
+	options := export.DefaultEventLogOptions
+	options.TurnInterval = 10
+	err := export.WriteEventLog(&log, events, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := log.String()
+	if strings.Contains(logged, "Flipped") {
+		t.Error("expected flip events to be left out of the log")
+	}
+
+	entries, err := export.ReadEventLog(&log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var turns []int
+	for i, entry := range entries {
+		if i > 0 && entry.Time.Before(entries[i-1].Time) {
+			t.Errorf("entry %v was logged before the entry preceding it", i)
+		}
+		switch e := entry.Event.(type) {
+		case gol.TurnComplete:
+			turns = append(turns, e.CompletedTurns)
+		case gol.FinalTurnComplete:
+			if e.CompletedTurns != 100 || e.Alive != nil {
+				t.Errorf("expected a summary of turn 100 without its alive cells, got %#v", e)
+			}
+		}
+	}
+	expectedTurns := []int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	if !reflect.DeepEqual(turns, expectedTurns) {
+		t.Errorf("expected TurnComplete events for turns %v, got %v", expectedTurns, turns)
+	}
+	if !strings.Contains(logged, `"type":"FinalTurnComplete","turn":100,"aliveCount":`) {
+		t.Error("expected the final turn summary to include the number of alive cells")
+	}
+	last := entries[len(entries)-1].Event
+	if state, ok := last.(gol.StateChange); !ok || state.NewState != gol.Quitting {
+		t.Errorf("expected the last entry to be the Quitting state change, got %v", last)
+	}
+}
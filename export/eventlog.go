@@ -0,0 +1,106 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// EventLogOptions describes which events are written to an event log.
+type EventLogOptions struct {
+	TurnInterval int  // A TurnComplete is logged every TurnInterval turns, 0 leaves them out
+	AliveCells   bool // Whether FinalTurnComplete is logged with its alive cells or only a count of them
+}
+
+// DefaultEventLogOptions logs every 100th turn and a summary of the final turn.
+var DefaultEventLogOptions = EventLogOptions{
+	TurnInterval: 100,
+	AliveCells:   false,
+}
+
+// LogEntry is a single event read back from an event log, along with the time it was logged.
+type LogEntry struct {
+	Time  time.Time
+	Event gol.Event
+}
+
+// finalSummary is how a FinalTurnComplete is logged when its alive cells are left out.
+type finalSummary struct {
+	Type       string `json:"type"`
+	Turn       int    `json:"turn"`
+	AliveCount int    `json:"aliveCount"`
+}
+
+// Returns true if the event should be written to the log
+func (options EventLogOptions) logs(event gol.Event) bool {
+	switch e := event.(type) {
+	case gol.CellFlipped, gol.CellsFlipped:
+		return false
+	case gol.TurnComplete:
+		return options.TurnInterval > 0 && e.CompletedTurns%options.TurnInterval == 0
+	}
+	return true
+}
+
+// Returns the event encoded as a single line of JSON, with the time it was logged as its first field
+func encodeLogEntry(loggedAt time.Time, event gol.Event, options EventLogOptions) ([]byte, error) {
+	var encoded []byte
+	var err error
+	if final, ok := event.(gol.FinalTurnComplete); ok && !options.AliveCells {
+		encoded, err = json.Marshal(finalSummary{"FinalTurnComplete", final.CompletedTurns, len(final.Alive)})
+	} else {
+		encoded, err = json.Marshal(event)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stamp, err := json.Marshal(loggedAt)
+	if err != nil {
+		return nil, err
+	}
+	line := append([]byte(`{"time":`), stamp...)
+	line = append(line, ',')
+	line = append(line, encoded[1:]...) // Every event is encoded as an object, so its opening brace is replaced
+	return append(line, '\n'), nil
+}
+
+// WriteEventLog writes the events that are not flips as JSON lines until the channel is closed.
+// If writing fails the remaining events are still received so that the run is never blocked.
+func WriteEventLog(w io.Writer, events <-chan gol.Event, options EventLogOptions) error {
+	var err error
+	for event := range events {
+		if err != nil || !options.logs(event) {
+			continue
+		}
+		var line []byte
+		line, err = encodeLogEntry(time.Now(), event, options)
+		if err == nil {
+			_, err = w.Write(line)
+		}
+	}
+	return err
+}
+
+// ReadEventLog reads back every entry of an event log written by WriteEventLog, in the order they were logged.
+func ReadEventLog(r io.Reader) ([]LogEntry, error) {
+	var entries []LogEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<30) // A FinalTurnComplete with its alive cells can be a very long line
+	for scanner.Scan() {
+		var stamp struct {
+			Time time.Time `json:"time"`
+		}
+		err := json.Unmarshal(scanner.Bytes(), &stamp)
+		if err != nil {
+			return nil, err
+		}
+		event, err := gol.UnmarshalEvent(scanner.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, LogEntry{Time: stamp.Time, Event: event})
+	}
+	return entries, scanner.Err()
+}
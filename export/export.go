@@ -0,0 +1,141 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// Options describes which turns of a run are captured and how each frame is drawn.
+type Options struct {
+	Interval  int           // A frame is captured every Interval completed turns
+	Scale     int           // Each cell is drawn as a Scale x Scale block of pixels
+	FirstTurn int           // Turns before FirstTurn are not captured
+	LastTurn  int           // Turns after LastTurn are not captured, a negative value means there is no limit
+	Palette   color.Palette // The first colour is used for dead cells and the second for alive cells
+	Delay     int           // The delay between GIF frames in 100ths of a second
+}
+
+// DefaultOptions captures every turn at a scale of 1 using black for dead cells and white for alive cells.
+var DefaultOptions = Options{
+	Interval:  1,
+	Scale:     1,
+	FirstTurn: 0,
+	LastTurn:  -1,
+	Palette:   color.Palette{color.Black, color.White},
+	Delay:     5,
+}
+
+// recorder rebuilds the world from the event stream so that frames can be drawn from it.
+type recorder struct {
+	params  gol.Params
+	options Options
+	world   [][]bool
+}
+
+// Returns a recorder for a world of the given size, filling in any unset options with their defaults
+func newRecorder(p gol.Params, options Options) (*recorder, error) {
+	if options.Interval <= 0 {
+		options.Interval = DefaultOptions.Interval
+	}
+	if options.Scale <= 0 {
+		options.Scale = DefaultOptions.Scale
+	}
+	if options.Palette == nil {
+		options.Palette = DefaultOptions.Palette
+	}
+	if len(options.Palette) != 2 {
+		return nil, errors.New("export: palette must contain exactly two colours")
+	}
+	world := make([][]bool, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]bool, p.ImageWidth)
+	}
+	return &recorder{params: p, options: options, world: world}, nil
+}
+
+// Returns true if a frame should be captured once the given number of turns have completed
+func (r *recorder) captures(completedTurns int) bool {
+	if completedTurns < r.options.FirstTurn {
+		return false
+	}
+	if r.options.LastTurn >= 0 && completedTurns > r.options.LastTurn {
+		return false
+	}
+	return (completedTurns-r.options.FirstTurn)%r.options.Interval == 0
+}
+
+// Returns an image of the current state of the world
+func (r *recorder) frame() *image.Paletted {
+	scale := r.options.Scale
+	bounds := image.Rect(0, 0, r.params.ImageWidth*scale, r.params.ImageHeight*scale)
+	img := image.NewPaletted(bounds, r.options.Palette)
+	for y, row := range r.world {
+		for x, alive := range row {
+			if !alive {
+				continue // Dead cells are already index 0 in the palette
+			}
+			for dy := 0; dy < scale; dy++ {
+				start := img.PixOffset(x*scale, y*scale+dy)
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[start+dx] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// Consumes events until the channel is closed, calling capture with a frame for every captured turn
+func (r *recorder) record(events <-chan gol.Event, capture func(completedTurns int, frame *image.Paletted) error) error {
+	var err error
+	for event := range events {
+		if err != nil {
+			continue // Keep draining so that the distributor is never blocked
+		}
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			r.world[e.Cell.Y][e.Cell.X] = !r.world[e.Cell.Y][e.Cell.X]
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				r.world[cell.Y][cell.X] = !r.world[cell.Y][cell.X]
+			}
+		case gol.ViewMoved:
+			for _, row := range r.world {
+				for x := range row {
+					row[x] = false
+				}
+			}
+			for _, cell := range e.Alive {
+				r.world[cell.Y][cell.X] = true
+			}
+		case gol.TurnComplete:
+			if r.captures(e.CompletedTurns) {
+				err = capture(e.CompletedTurns, r.frame())
+			}
+		}
+	}
+	return err
+}
+
+// ParsePalette parses a comma separated pair of hex colours, such as "000000,ffffff", into a palette.
+func ParsePalette(s string) (color.Palette, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("export: palette %q must contain exactly two colours", s)
+	}
+	var palette color.Palette
+	for _, field := range fields {
+		field = strings.TrimPrefix(strings.TrimSpace(field), "#")
+		value, err := strconv.ParseUint(field, 16, 32)
+		if err != nil || len(field) != 6 {
+			return nil, fmt.Errorf("export: invalid colour %q", field)
+		}
+		palette = append(palette, color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xFF})
+	}
+	return palette, nil
+}
@@ -0,0 +1,37 @@
+package export
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// WriteFrames consumes events until the channel is closed and writes each captured turn to dir as a numbered PNG.
+// Frames are numbered from 0 in the order they were captured, e.g. frame00000.png, frame00001.png...
+func WriteFrames(dir string, p gol.Params, events <-chan gol.Event, options Options) error {
+	r, err := newRecorder(p, options)
+	if err == nil {
+		err = os.MkdirAll(dir, os.ModePerm)
+	}
+	if err != nil {
+		for range events {
+		}
+		return err
+	}
+	frameNum := 0
+	return r.record(events, func(completedTurns int, frame *image.Paletted) error {
+		file, err := os.Create(filepath.Join(dir, fmt.Sprintf("frame%05d.png", frameNum)))
+		if err != nil {
+			return err
+		}
+		frameNum++
+		err = png.Encode(file, frame)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
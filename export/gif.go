@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"io"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// gifLoopForever is the application extension that makes an animation loop forever, which image/gif only writes for
+// GIFs of more than one frame.
+var gifLoopForever = []byte{0x21, 0xff, 11, 'N', 'E', 'T', 'S', 'C', 'A', 'P', 'E', '2', '.', '0', 3, 1, 0, 0, 0}
+
+// WriteGIF consumes events until the channel is closed and writes the captured turns to w as an animated GIF.
+// Each frame is encoded as soon as it is captured, so long runs do not keep every frame in memory.
+func WriteGIF(w io.Writer, p gol.Params, events <-chan gol.Event, options Options) error {
+	r, err := newRecorder(p, options)
+	if err != nil {
+		for range events {
+		}
+		return err
+	}
+	g := &gifWriter{w: w, delay: r.options.Delay}
+	err = r.record(events, g.writeFrame)
+	if err == nil && g.frames == 0 {
+		err = errors.New("export: no turns were captured for the GIF")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte{0x3b}) // The trailer that ends the GIF
+	return err
+}
+
+// gifWriter writes an animated GIF a frame at a time.
+// Each frame is encoded by image/gif as a GIF of its own, whose header is written for the first frame only and whose
+// trailer is left off, as every frame has the same size and palette.
+type gifWriter struct {
+	w       io.Writer
+	delay   int
+	frames  int
+	encoded bytes.Buffer // Reused for each frame, so it only ever holds one
+}
+
+// Encodes a frame with its delay and writes it, writing the header first if it is the first frame
+func (g *gifWriter) writeFrame(completedTurns int, frame *image.Paletted) error {
+	g.encoded.Reset()
+	err := gif.EncodeAll(&g.encoded, &gif.GIF{Image: []*image.Paletted{frame}, Delay: []int{g.delay}})
+	if err != nil {
+		return err
+	}
+	encoded := g.encoded.Bytes()
+	header := gifHeaderLength(encoded)
+	if g.frames == 0 {
+		_, err = g.w.Write(encoded[:header])
+		if err == nil {
+			_, err = g.w.Write(gifLoopForever)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	g.frames++
+	_, err = g.w.Write(encoded[header : len(encoded)-1])
+	return err
+}
+
+// Returns the length of the signature, screen descriptor and global colour table a GIF starts with
+func gifHeaderLength(encoded []byte) int {
+	length := 13
+	if flags := encoded[10]; flags&0x80 != 0 { // The last 3 bits give the size of the table as a power of 2
+		length += 3 << (flags&7 + 1)
+	}
+	return length
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/export"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Returns the cells of an exported frame that are drawn with the alive colour
+func frameAliveCells(frame image.Image, scale int) []util.Cell {
+	var cells []util.Cell
+	bounds := frame.Bounds()
+	for y := 0; y < bounds.Dy(); y += scale {
+		for x := 0; x < bounds.Dx(); x += scale {
+			r, _, _, _ := frame.At(x, y).RGBA()
+			if r != 0 {
+				cells = append(cells, util.Cell{X: x / scale, Y: y / scale})
+			}
+		}
+	}
+	return cells
+}
+
+// TestExportGIF checks that a 16x16 run over 100 turns produces one GIF frame per turn and that the last frame matches the expected image.
+func TestExportGIF(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4}
+	options := export.DefaultOptions
+	options.Scale = 2
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	var buffer bytes.Buffer
+	err := export.WriteGIF(&buffer, p, events, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	animation, err := gif.DecodeAll(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != p.Turns+1 {
+		t.Fatalf("expected %v frames, got %v", p.Turns+1, len(animation.Image))
+	}
+	expectedAlive := util.ReadAliveCells("check/images/16x16x100.pgm", p.ImageWidth, p.ImageHeight)
+	lastFrame := animation.Image[len(animation.Image)-1]
+	assertEqualBoard(t, frameAliveCells(lastFrame, options.Scale), expectedAlive, p)
+}
+
+// cancellingWriter cancels a run once a number of bytes have been written to it.
+type cancellingWriter struct {
+	bytes.Buffer
+	limit  int
+	cancel context.CancelFunc
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) >= w.limit {
+		w.cancel()
+	}
+	return w.Buffer.Write(p)
+}
+
+// TestExportGIFStreams exports a run with no last turn, checking that frames are written out while it is still going
+// rather than kept until it ends, and that the frames written by then can be decoded.
+func TestExportGIFStreams(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan gol.Event)
+	go gol.RunContext(ctx, p, events, nil)
+	w := &cancellingWriter{limit: 100000, cancel: cancel}
+	err := export.WriteGIF(w, p, events, export.DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	animation, err := gif.DecodeAll(&w.Buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) < 2 || animation.LoopCount != 0 || animation.Delay[0] != export.DefaultOptions.Delay {
+		t.Fatalf("expected a looping animation with a delay of %v, got %v frames, loop count %v and delays %v",
+			export.DefaultOptions.Delay, len(animation.Image), animation.LoopCount, animation.Delay)
+	}
+	last := len(animation.Image) - 1
+	simulator, err := gol.NewSimulator(p, util.ReadAliveCells("images/64x64.pgm", p.ImageWidth, p.ImageHeight))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer simulator.Close()
+	err = simulator.Step(last) // The first frame is of turn 0
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualBoard(t, frameAliveCells(animation.Image[last], 1), simulator.Snapshot().AliveCells(), p)
+}
+
+// TestExportGIFLarge exports a random 512x512 world drawn at a scale of 2 with a custom palette, where each frame is
+// big enough for the LZW code table to fill up and be reset several times, and checks every frame.
+func TestExportGIFLarge(t *testing.T) {
+	cells := randomCells(512, 512, 1)
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 2, Threads: 4, InitialCells: cells}
+	options := export.DefaultOptions
+	options.Scale = 2
+	options.Palette = color.Palette{color.RGBA{G: 0x20, B: 0x30, A: 0xff}, color.RGBA{R: 0xff, A: 0xff}}
+	events := make(chan gol.Event)
+	go gol.RunContext(context.Background(), p, events, nil)
+	var buffer bytes.Buffer
+	err := export.WriteGIF(&buffer, p, events, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	animation, err := gif.DecodeAll(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != p.Turns+1 {
+		t.Fatalf("expected %v frames, got %v", p.Turns+1, len(animation.Image))
+	}
+	simulator, err := gol.NewSimulator(p, cells)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer simulator.Close()
+	for turn, frame := range animation.Image {
+		if !reflect.DeepEqual(frame.Palette, options.Palette) {
+			t.Errorf("expected the palette %v, got %v", options.Palette, frame.Palette)
+		}
+		if turn > 0 {
+			err = simulator.Step(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		assertEqualBoard(t, frameAliveCells(frame, options.Scale), simulator.Snapshot().AliveCells(), p)
+	}
+}
+
+// TestExportGIFNothingCaptured checks that a palette that is not two colours and a range of turns the run never
+// reaches are both errors, and that the run is still drained to the end.
+func TestExportGIFNothingCaptured(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 1}
+	threeColours := export.DefaultOptions
+	threeColours.Palette = color.Palette{color.Black, color.White, color.Gray{Y: 0x80}}
+	tooLate := export.DefaultOptions
+	tooLate.FirstTurn = 11
+	backwards := export.DefaultOptions
+	backwards.FirstTurn, backwards.LastTurn = 5, 4
+	for name, options := range map[string]export.Options{
+		"three colours": threeColours,
+		"too late":      tooLate,
+		"backwards":     backwards,
+	} {
+		t.Run(name, func(t *testing.T) {
+			events := make(chan gol.Event)
+			result := make(chan error, 1)
+			go func() {
+				result <- gol.RunContext(context.Background(), p, events, nil)
+			}()
+			var buffer bytes.Buffer
+			err := export.WriteGIF(&buffer, p, events, options)
+			if err == nil {
+				t.Error("expected an error")
+			}
+			if err := <-result; err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestExportFrames checks that only the turns in the requested range and interval are written as PNG frames.
+func TestExportFrames(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 8}
+	options := export.DefaultOptions
+	options.Interval = 10
+	options.FirstTurn = 1
+	options.LastTurn = 91
+	dir, err := ioutil.TempDir("", "frames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	err = export.WriteFrames(dir, p, events, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 10 {
+		t.Fatalf("expected 10 frames, got %v", len(files))
+	}
+	file, err := os.Open(filepath.Join(dir, fmt.Sprintf("frame%05d.png", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	frame, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedAlive := util.ReadAliveCells("check/images/64x64x1.pgm", p.ImageWidth, p.ImageHeight)
+	assertEqualBoard(t, frameAliveCells(frame, options.Scale), expectedAlive, p)
+}
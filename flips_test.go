@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// flipModes are the names used for each flip mode in subtests and benchmarks.
+var flipModes = map[string]gol.FlipMode{
+	"cell":  gol.FlipCells,
+	"batch": gol.FlipBatches,
+	"none":  gol.FlipNone,
+}
+
+// TestFlipModes runs a 64x64 image for 100 turns in each flip mode, checking that the flips reported add up to the
+// expected image and that no flips are reported when they are disabled.
+func TestFlipModes(t *testing.T) {
+	expected := util.ReadAliveCells("check/images/64x64x100.pgm", 64, 64)
+	for name, mode := range flipModes {
+		p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 5, FlipEvents: mode}
+		t.Run(name, func(t *testing.T) {
+			events := make(chan gol.Event)
+			gol.Run(p, events, nil)
+			var received []gol.Event
+			cellFlipped, cellsFlipped := 0, 0
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CellFlipped:
+					cellFlipped++
+				case gol.CellsFlipped:
+					cellsFlipped++
+				case gol.FinalTurnComplete:
+					assertEqualBoard(t, e.Alive, expected, p)
+				}
+				received = append(received, event)
+			}
+			switch mode {
+			case gol.FlipCells:
+				if cellsFlipped > 0 {
+					t.Errorf("expected no CellsFlipped events, got %v", cellsFlipped)
+				}
+			case gol.FlipBatches:
+				if cellFlipped > 0 {
+					t.Errorf("expected no CellFlipped events, got %v", cellFlipped)
+				}
+				if cellsFlipped > 1+p.Turns*p.Threads { // One for the initial image, then one per worker each turn
+					t.Errorf("expected at most %v CellsFlipped events, got %v", 1+p.Turns*p.Threads, cellsFlipped)
+				}
+			case gol.FlipNone:
+				if cellFlipped+cellsFlipped > 0 {
+					t.Errorf("expected no flip events, got %v", cellFlipped+cellsFlipped)
+				}
+				return
+			}
+			assertEqualBoard(t, applyFlips(received, p), expected, p)
+		})
+	}
+}
+
+// BenchmarkFlipModes compares how long 100 turns of the 512x512 image take with each flip mode.
+func BenchmarkFlipModes(b *testing.B) {
+	os.Stdout = nil // Disable all program output apart from benchmark results
+	for _, name := range []string{"cell", "batch", "none"} {
+		params := gol.Params{
+			Turns:       100,
+			Threads:     8,
+			ImageWidth:  512,
+			ImageHeight: 512,
+			FlipEvents:  flipModes[name],
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				events := make(chan gol.Event)
+				gol.Run(params, events, nil)
+				for range events {
+				}
+			}
+		})
+	}
+}
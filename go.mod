@@ -5,6 +5,7 @@ go 1.12
 require (
 	github.com/ChrisGora/benchgraph v0.0.0-20190810104645-dd14afd4debc // indirect
 	github.com/fatih/color v1.10.0 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/veandco/go-sdl2 v0.4.4
 	golang.org/x/tools v0.0.0-20201119174615-0557df368a99 // indirect
 )
@@ -0,0 +1,119 @@
+package gol
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SaveKind says why an image was written.
+type SaveKind string
+
+const (
+	// ManualSave images were asked for with a SaveCommand or the 's' key.
+	ManualSave SaveKind = "save"
+	// PeriodicSave images were written automatically every Params.SaveEveryTurns turns or Params.SaveEvery.
+	PeriodicSave SaveKind = "periodic"
+	// FinalSave images were written at the end of the run.
+	FinalSave SaveKind = "final"
+)
+
+// ManifestEntry records an image written during a run.
+type ManifestEntry struct {
+	Turn int       `json:"turn"`
+	File string    `json:"file"` // The name of the image, relative to the directory of the manifest
+	Kind SaveKind  `json:"kind"`
+	Time time.Time `json:"time"`
+}
+
+// manifest lists the images a run has written that are still kept, and removes periodic saves that are no longer.
+// It is rewritten in full after every image, alongside the images as <w>x<h>.manifest.json.
+type manifest struct {
+	dir       string
+	path      string
+	keep      int // The number of periodic saves to keep, 0 keeps them all
+	extension string
+	entries   []ManifestEntry
+}
+
+// Returns the manifest for a run, or nil if it does not save periodically
+func newManifest(p Params, fileName string) *manifest {
+	if p.SaveEveryTurns <= 0 && p.SaveEvery <= 0 {
+		return nil
+	}
+	dir := outputDir(p)
+	return &manifest{
+		dir:       dir,
+		path:      filepath.Join(dir, fileName+".manifest.json"),
+		keep:      p.KeepSaves,
+		extension: imageExtension(p.ImageFormat),
+	}
+}
+
+// Records an image that has been written, removes the oldest periodic saves beyond those kept and rewrites the file
+// Saves are written one at a time, so this is never called concurrently
+func (m *manifest) record(turn int, name string, kind SaveKind) error {
+	if m == nil {
+		return nil
+	}
+	m.entries = append(m.entries, ManifestEntry{Turn: turn, File: name + m.extension, Kind: kind, Time: time.Now()})
+	periodic := 0
+	for _, entry := range m.entries {
+		if entry.Kind == PeriodicSave {
+			periodic++
+		}
+	}
+	var kept []ManifestEntry
+	for _, entry := range m.entries {
+		if entry.Kind == PeriodicSave && m.keep > 0 && periodic > m.keep {
+			periodic--
+			if !m.lists(entry.File, entry) {
+				err := os.Remove(filepath.Join(m.dir, entry.File))
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	m.entries = kept
+	return m.write()
+}
+
+// Returns true if another entry than the one given has the same file, which must then not be removed
+func (m *manifest) lists(file string, except ManifestEntry) bool {
+	for _, entry := range m.entries {
+		if entry.File == file && entry != except {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes the manifest to a temporary file and then moves it into place, so that it is never seen half written
+func (m *manifest) write() error {
+	data, err := json.MarshalIndent(m.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	temporary := m.path + ".tmp"
+	err = ioutil.WriteFile(temporary, append(data, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporary, m.path)
+}
+
+// ReadManifest reads the manifest written alongside the images of a run that saves periodically.
+func ReadManifest(path string) ([]ManifestEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ManifestEntry
+	err = json.Unmarshal(data, &entries)
+	return entries, err
+}
@@ -0,0 +1,142 @@
+package gol
+
+import (
+	"context"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// board is the world of a run, stored in whichever way the run's Backend asks for.
+// A board is never changed by calculating the next turn, which returns a new board, so a board can be shared with
+// saves in the background as long as it is copied before being edited.
+type board interface {
+	// next returns the board after another turn, reporting the cells that change as flips of the given turn
+	next(ctx context.Context, turn int) (board, error)
+	// setRow sets a row of the image while the board is loaded, keeping the row if it needs to
+	setRow(y int, row []byte)
+	// row returns a row of the image of the board, which must not be changed
+	row(y int) []byte
+	// rows returns a copy of the image of the board
+	rows() [][]byte
+	// height returns the number of rows in the image of the board
+	height() int
+	// set makes a cell of the image alive or dead, returning the cell wrapped onto the image and true if a flip should
+	// be reported
+	set(cell util.Cell, alive bool) (util.Cell, bool)
+	// alive returns every alive cell, ordered by row and then by column
+	alive() []util.Cell
+	// count returns the number of alive cells
+	count() int
+	// copy returns a board that can be edited without changing this one
+	copy() board
+	// follow moves the image to keep up with the population, returning true if it moved
+	follow() bool
+	// origin returns where the top left cell of the image is in the coordinates of alive
+	origin() util.Cell
+	// imageCells returns the alive cells within the image, in its coordinates
+	imageCells() []util.Cell
+}
+
+// strips are the parts of a dense world that each worker calculates, along with the channels to the workers.
+type strips struct {
+	parts          []chan [][]byte
+	startYValues   []int
+	sectionHeights []int
+	threads        int
+}
+
+// Returns the strips of a world of the given height split between the given number of workers
+// Every strip has at least one row, so there are no more workers than rows
+func newStrips(height int, threads int) *strips {
+	threads = minInt(threads, height)
+	sectionHeights := calcSectionHeights(height, threads)
+	return &strips{
+		parts:          createPartChannels(threads),
+		startYValues:   calcStartYValues(sectionHeights),
+		sectionHeights: sectionHeights,
+		threads:        threads,
+	}
+}
+
+// denseBoard stores a byte for every cell of the world, and calculates turns with the workers of either its strips
+// or its tiles.
+type denseBoard struct {
+	cells  [][]byte
+	strips *strips
+	tiles  *tiles // Used instead of the strips when not nil
+}
+
+// Returns a dense board of the given height, whose rows are set as it is loaded
+func newDenseBoard(height int, strips *strips, tiles *tiles) *denseBoard {
+	return &denseBoard{cells: make([][]byte, height), strips: strips, tiles: tiles}
+}
+
+func (b *denseBoard) next(ctx context.Context, turn int) (board, error) {
+	var cells [][]byte
+	var err error
+	if b.tiles != nil {
+		cells, err = b.tiles.calcNextWorld(ctx, b.cells, turn)
+	} else {
+		s := b.strips
+		cells, err = calcNextWorld(ctx, s.parts, s.startYValues, s.sectionHeights, b.cells, s.threads)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &denseBoard{cells: cells, strips: b.strips, tiles: b.tiles}, nil
+}
+
+func (b *denseBoard) setRow(y int, row []byte) {
+	b.cells[y] = row
+}
+
+func (b *denseBoard) row(y int) []byte {
+	return b.cells[y]
+}
+
+func (b *denseBoard) rows() [][]byte {
+	return copyWorld(b.cells)
+}
+
+func (b *denseBoard) height() int {
+	return len(b.cells)
+}
+
+func (b *denseBoard) set(cell util.Cell, alive bool) (util.Cell, bool) {
+	height, width := len(b.cells), len(b.cells[0])
+	cell.X = ((cell.X % width) + width) % width // Wrap cells that fall outside the world around the edges
+	cell.Y = ((cell.Y % height) + height) % height
+	value := byte(0)
+	if alive {
+		value = 255
+	}
+	if b.cells[cell.Y][cell.X] == value {
+		return cell, false
+	}
+	b.cells[cell.Y][cell.X] = value
+	return cell, true
+}
+
+func (b *denseBoard) alive() []util.Cell {
+	return getAliveCells(b.cells)
+}
+
+func (b *denseBoard) count() int {
+	return calcNumAliveCells(b.cells)
+}
+
+func (b *denseBoard) copy() board {
+	return &denseBoard{cells: copyWorld(b.cells), strips: b.strips, tiles: b.tiles}
+}
+
+// follow never moves the image of a dense board, which is the whole world
+func (b *denseBoard) follow() bool {
+	return false
+}
+
+func (b *denseBoard) origin() util.Cell {
+	return util.Cell{}
+}
+
+func (b *denseBoard) imageCells() []util.Cell {
+	return b.alive()
+}
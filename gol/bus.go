@@ -0,0 +1,280 @@
+package gol
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Policy says what a subscription does when its consumer falls behind and its buffer is full.
+type Policy int
+
+const (
+	// Block makes the publisher wait until the consumer has made room, so no events are lost.
+	Block Policy = iota
+	// DropOldest discards the oldest buffered event to make room, so the publisher never waits.
+	DropOldest
+	// Coalesce merges the CellFlipped and CellsFlipped events of each turn into a single CellsFlipped event holding
+	// the cells that changed, where a cell flipped twice in the same turn is left out. Other events block like Block.
+	Coalesce
+)
+
+// SubscribeOptions configure a subscription to a Bus.
+type SubscribeOptions struct {
+	// Filter returns true for the events the subscriber wants, nil means every event.
+	Filter func(Event) bool
+	// Policy is what happens once Buffer events are waiting to be received.
+	Policy Policy
+	// Buffer is the number of events that can wait to be received, at least 1.
+	Buffer int
+}
+
+// Subscription is a single consumer of the events published on a Bus.
+type Subscription struct {
+	// Events receives the subscribed events in the order they were published, and is closed once the bus is closed
+	// or the subscription is cancelled.
+	Events <-chan Event
+
+	bus       *Bus
+	options   SubscribeOptions
+	mutex     sync.Mutex
+	changed   *sync.Cond
+	queue     []Event
+	flips     map[util.Cell]bool // Cells flipped an odd number of times in flipsTurn, while coalescing
+	flipsTurn int
+	closed    bool
+	cancelled chan bool
+	out       chan Event
+}
+
+// Bus delivers every event published on it to each of its subscribers, each with its own filter and buffer, so that
+// a run can have several consumers and a slow one does not have to stall the others.
+type Bus struct {
+	mutex       sync.Mutex
+	subscribers []*Subscription
+	closed      bool
+}
+
+// EventsOfType returns a filter that accepts events of the same types as the examples given.
+func EventsOfType(examples ...Event) func(Event) bool {
+	types := make(map[reflect.Type]bool)
+	for _, example := range examples {
+		types[reflect.TypeOf(example)] = true
+	}
+	return func(event Event) bool {
+		return types[reflect.TypeOf(event)]
+	}
+}
+
+// NewBus returns a bus without any subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a subscriber that receives the events published from now on.
+// Subscribing to a bus that has already been closed returns a subscription whose Events channel is closed.
+func (b *Bus) Subscribe(options SubscribeOptions) *Subscription {
+	if options.Buffer < 1 {
+		options.Buffer = 1
+	}
+	out := make(chan Event)
+	s := &Subscription{
+		Events:    out,
+		bus:       b,
+		options:   options,
+		flips:     make(map[util.Cell]bool),
+		cancelled: make(chan bool),
+		out:       out,
+	}
+	s.changed = sync.NewCond(&s.mutex)
+	go s.deliver()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		s.close()
+	} else {
+		b.subscribers = append(b.subscribers, s)
+	}
+	return s
+}
+
+// Publish passes an event to every subscriber, waiting only for subscribers with the Block policy that are full.
+func (b *Bus) Publish(event Event) {
+	b.mutex.Lock()
+	subscribers := b.subscribers
+	b.mutex.Unlock()
+	for _, s := range subscribers {
+		s.publish(event)
+	}
+}
+
+// Close closes the Events channel of every subscriber once it has received the events already published.
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	subscribers := b.subscribers
+	b.subscribers = nil
+	b.closed = true
+	b.mutex.Unlock()
+	for _, s := range subscribers {
+		s.close()
+	}
+}
+
+// Backlog returns the most events waiting to be received by any one subscriber, which grows while a consumer falls
+// behind.
+func (b *Bus) Backlog() int {
+	b.mutex.Lock()
+	subscribers := b.subscribers
+	b.mutex.Unlock()
+	backlog := 0
+	for _, s := range subscribers {
+		s.mutex.Lock()
+		if len(s.queue) > backlog {
+			backlog = len(s.queue)
+		}
+		s.mutex.Unlock()
+	}
+	return backlog
+}
+
+// Forward publishes every event from a run's events channel, then closes the bus once the channel is closed.
+func (b *Bus) Forward(events <-chan Event) {
+	for event := range events {
+		b.Publish(event)
+	}
+	b.Close()
+}
+
+// Cancel stops the subscription straight away, dropping any events that have not been received.
+func (s *Subscription) Cancel() {
+	s.bus.mutex.Lock()
+	for i, subscriber := range s.bus.subscribers {
+		if subscriber == s {
+			s.bus.subscribers = append(s.bus.subscribers[:i:i], s.bus.subscribers[i+1:]...)
+			break
+		}
+	}
+	s.bus.mutex.Unlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-s.cancelled:
+	default:
+		close(s.cancelled)
+	}
+	s.closed = true
+	s.queue = nil
+	s.changed.Broadcast()
+}
+
+// Adds an event to the queue, making room for it according to the subscription's policy
+// The mutex must be held
+func (s *Subscription) enqueue(event Event) {
+	if s.options.Policy == DropOldest {
+		if len(s.queue) >= s.options.Buffer {
+			s.queue = s.queue[1:]
+		}
+	} else {
+		for len(s.queue) >= s.options.Buffer && !s.closed {
+			s.changed.Wait()
+		}
+		if s.closed {
+			return
+		}
+	}
+	s.queue = append(s.queue, event)
+	s.changed.Broadcast()
+}
+
+// Queues the cells flipped so far as a single CellsFlipped event, sorted by row then column
+// The mutex must be held
+func (s *Subscription) flushFlips() {
+	if len(s.flips) == 0 {
+		return
+	}
+	cells := make([]util.Cell, 0, len(s.flips))
+	for cell := range s.flips {
+		cells = append(cells, cell)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].Y < cells[j].Y || (cells[i].Y == cells[j].Y && cells[i].X < cells[j].X)
+	})
+	s.flips = make(map[util.Cell]bool)
+	s.enqueue(CellsFlipped{CompletedTurns: s.flipsTurn, Cells: cells})
+}
+
+// Passes a published event on to the queue if the subscriber wants it
+func (s *Subscription) publish(event Event) {
+	if s.options.Filter != nil && !s.options.Filter(event) {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	if s.options.Policy == Coalesce {
+		switch e := event.(type) {
+		case CellFlipped:
+			s.addFlips(e.CompletedTurns, e.Cell)
+			return
+		case CellsFlipped:
+			s.addFlips(e.CompletedTurns, e.Cells...)
+			return
+		}
+		s.flushFlips()
+	}
+	s.enqueue(event)
+}
+
+// Merges flipped cells into those of their turn, queueing the cells of an earlier turn first
+// The mutex must be held
+func (s *Subscription) addFlips(turn int, cells ...util.Cell) {
+	if turn != s.flipsTurn {
+		s.flushFlips()
+		s.flipsTurn = turn
+	}
+	for _, cell := range cells {
+		if s.flips[cell] {
+			delete(s.flips, cell) // Flipping a cell back leaves it unchanged
+		} else {
+			s.flips[cell] = true
+		}
+	}
+}
+
+// Stops accepting events, letting the ones already queued be delivered before the Events channel is closed
+func (s *Subscription) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.options.Policy == Coalesce {
+		s.flushFlips()
+	}
+	s.closed = true
+	s.changed.Broadcast()
+}
+
+// Sends queued events to the consumer until the subscription is closed and its queue is empty, or it is cancelled
+func (s *Subscription) deliver() {
+	defer close(s.out)
+	for {
+		s.mutex.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.changed.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mutex.Unlock()
+			return
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.changed.Broadcast() // Wakes publishers waiting for room in the queue
+		s.mutex.Unlock()
+		select {
+		case <-s.cancelled:
+			return
+		case s.out <- event:
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package gol
+
+import (
+	"errors"
+)
+
+// CommandType says what a Command asks the distributor to do.
+type CommandType int
+
+const (
+	// PauseCommand pauses the run between turns, doing nothing if it is already paused.
+	PauseCommand CommandType = iota
+	// ResumeCommand resumes a paused run, doing nothing if it is not paused.
+	ResumeCommand
+	// StepCommand performs a single turn of a paused run.
+	StepCommand
+	// SaveCommand writes a copy of the current world to a PGM image in the background, while turns carry on.
+	// Saves asked for while one is being written share a single image, whose turn is in the Reply.
+	SaveCommand
+	// QuitCommand stops the run after the current turn, writing the final image as if all turns had been performed.
+	QuitCommand
+	// ShutdownCommand stops the run after the current turn without writing the final image.
+	ShutdownCommand
+	// SetSpeedCommand limits the run to Command.TurnsPerSecond, where 0 means as fast as possible.
+	SetSpeedCommand
+	// EditCellsCommand applies Command.Edits to the world between turns.
+	EditCellsCommand
+	// SnapshotCommand replies with a copy of the current world.
+	SnapshotCommand
+)
+
+// Command asks the distributor to control a run, and is sent on the channel given to RunCommands.
+// If Reply is not nil the outcome is sent on it once the command has been handled, and the run waits for it to be
+// received, so a Reply that is not buffered must be read from.
+// Commands that arrive after the run has finished are never replied to.
+type Command struct {
+	Type           CommandType
+	TurnsPerSecond int
+	Edits          []CellEdit
+	Reply          chan<- Reply
+}
+
+// Reply is the outcome of a Command.
+// CompletedTurns is the number of turns completed when the command was handled, or the turn saved by SaveCommand,
+// Filename is set by SaveCommand and Snapshot by SnapshotCommand.
+type Reply struct {
+	CompletedTurns int
+	Filename       string
+	Snapshot       Snapshot
+	Err            error
+}
+
+// ErrNotPaused is replied to a StepCommand sent while the run is not paused.
+var ErrNotPaused = errors.New("gol: a single turn can only be stepped while paused")
+
+// Returns the command a key press from the SDL window or terminal stands for, given whether the run is paused and
+// its current speed, and false if the key does not control the run
+func keyCommand(key rune, paused bool, turnsPerSecond int) (Command, bool) {
+	switch key {
+	case 's':
+		return Command{Type: SaveCommand}, true
+	case 'q':
+		return Command{Type: QuitCommand}, true
+	case 'k':
+		return Command{Type: ShutdownCommand}, true
+	case 'p':
+		if paused {
+			return Command{Type: ResumeCommand}, true
+		}
+		return Command{Type: PauseCommand}, true
+	case 'n':
+		return Command{Type: StepCommand}, true
+	case '+', '=', '-', 'm':
+		return Command{Type: SetSpeedCommand, TurnsPerSecond: changeSpeed(turnsPerSecond, key)}, true
+	}
+	return Command{}, false
+}
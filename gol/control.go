@@ -0,0 +1,360 @@
+package gol
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errSaveStopped is the reply to saves that were still waiting to be written when the run stopped.
+var errSaveStopped = errors.New("gol: the run stopped before the save was written")
+
+// turnResult is the next world calculated by a turn running in the background.
+type turnResult struct {
+	world board
+	err   error
+}
+
+// controller owns the world and the state of a run between turns.
+// Turns are calculated in the background so that commands are serviced straight away, even while the workers are
+// busy. The world only changes when a turn finishes, so saves and snapshots use the last completed world, while
+// anything that needs to report the state after a turn (pausing, resuming and edits) waits for it to finish.
+// Each turn produces a new world, so saves share it with the controller rather than copying it, and edits copy it
+// before changing it while it is shared.
+type controller struct {
+	ctx            context.Context
+	c              distributorChannels
+	fileName       string
+	world          board
+	completedTurns int
+	turnsPerSecond int
+	flips          FlipMode
+	metrics        *Metrics
+	nextTurnTime   time.Time
+	paused         bool
+	steps          int  // Turns still to be performed one at a time while paused
+	stopping       bool // Set by a quit or shutdown, the run stops once the turn in progress has finished
+	writeImage     bool // Whether the final image should be written when the run stops
+	turnDone       chan turnResult
+	saving         bool         // Set while a save is being written in the background
+	nextSave       *pendingSave // Asked for while saving, and written once that save has finished
+	saveDone       chan error   // Receives the result of each save once it has been written
+	worldShared    bool         // Set while the world may be being written by a save, so must not be changed
+	saveEveryTurns int
+	saveEvery      time.Duration
+	lastPeriodic   int // The turn of the last periodic save, so that a paused run is not saved again and again
+	manifest       *manifest
+	pendingStates  []State
+	pendingEdits   []Command
+	pendingReplies []Command
+}
+
+// pendingSave is a save waiting for the one being written to finish. Saves asked for in the meantime are combined
+// into it, so that at most two worlds are ever held for saving however quickly saves are asked for.
+type pendingSave struct {
+	world    board
+	turns    int
+	kind     SaveKind  // A manual save if any of the saves combined into it were
+	commands []Command // The commands waiting for a reply once it has been written
+}
+
+// Sends a reply to a command if it asked for one, waiting for it to be received unless the run is cancelled
+func reply(ctx context.Context, command Command, reply Reply) {
+	if command.Reply == nil {
+		return
+	}
+	select {
+	case command.Reply <- reply:
+	case <-ctx.Done():
+	}
+}
+
+// Returns true if a turn is being calculated in the background
+func (ctl *controller) busy() bool {
+	return ctl.turnDone != nil
+}
+
+// Applies a set of cell edits to the world, reporting each cell that changes
+func (ctl *controller) applyCellEdits(edits []CellEdit) error {
+	reporter := newFlipReporter(ctl.ctx, ctl.c.events, ctl.flips, ctl.completedTurns)
+	if ctl.worldShared { // Copied on write so that saves in the background are not changed
+		ctl.world = ctl.world.copy()
+		ctl.worldShared = false
+	}
+	for _, edit := range edits {
+		cell, flipped := ctl.world.set(edit.Cell, edit.Alive)
+		if flipped {
+			err := reporter.flip(cell)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := reporter.flush()
+	if err != nil {
+		return err
+	}
+	return sendEvent(ctl.ctx, ctl.c.events, CellsEdited{ // So that the GUI renders the edited world
+		CompletedTurns: ctl.completedTurns,
+	})
+}
+
+// Announces state changes, applies edits and sends replies that were waiting for the turn in progress to finish
+func (ctl *controller) flushPending() error {
+	for _, state := range ctl.pendingStates {
+		err := sendEvent(ctl.ctx, ctl.c.events, StateChange{ctl.completedTurns, state})
+		if err != nil {
+			return err
+		}
+	}
+	for _, command := range ctl.pendingEdits {
+		err := ctl.applyCellEdits(command.Edits)
+		if err != nil {
+			return err
+		}
+		ctl.pendingReplies = append(ctl.pendingReplies, command)
+	}
+	for _, command := range ctl.pendingReplies {
+		reply(ctl.ctx, command, Reply{CompletedTurns: ctl.completedTurns})
+	}
+	ctl.pendingStates, ctl.pendingEdits, ctl.pendingReplies = nil, nil, nil
+	return nil
+}
+
+// Carries out a single command, returning an error if the run cannot continue
+func (ctl *controller) handle(command Command) error {
+	result := Reply{CompletedTurns: ctl.completedTurns}
+	switch command.Type {
+	case SaveCommand:
+		ctl.requestSave(command, ManualSave)
+		return nil
+	case SnapshotCommand:
+		result.Snapshot = Snapshot{CompletedTurns: ctl.completedTurns, World: ctl.world.rows()}
+	case QuitCommand, ShutdownCommand: // Quitting writes the final image but shutting down does not
+		ctl.stopping = true
+		ctl.writeImage = command.Type == QuitCommand
+	case SetSpeedCommand:
+		ctl.turnsPerSecond = command.TurnsPerSecond
+		if ctl.turnsPerSecond < 0 {
+			ctl.turnsPerSecond = 0
+		}
+		ctl.nextTurnTime = time.Now()
+		err := sendEvent(ctl.ctx, ctl.c.events, SpeedChange{ctl.completedTurns, ctl.turnsPerSecond})
+		if err != nil {
+			return err
+		}
+	case StepCommand: // Step a single turn, only while paused
+		if !ctl.paused {
+			result.Err = ErrNotPaused
+		} else {
+			ctl.steps++
+		}
+	case PauseCommand, ResumeCommand:
+		if ctl.paused == (command.Type == PauseCommand) {
+			break // Already in the state asked for
+		}
+		ctl.paused = !ctl.paused
+		ctl.steps = 0
+		newState := Continuing
+		if ctl.paused {
+			newState = Paused
+		}
+		ctl.pendingStates = append(ctl.pendingStates, newState)
+		ctl.pendingReplies = append(ctl.pendingReplies, command)
+		if ctl.busy() { // Reported once the turn in progress has finished, so that the turn number is right
+			return nil
+		}
+		return ctl.flushPending()
+	case EditCellsCommand:
+		ctl.pendingEdits = append(ctl.pendingEdits, command)
+		if ctl.busy() { // Applied once the turn in progress has finished, as it is still reading the world
+			return nil
+		}
+		return ctl.flushPending()
+	}
+	reply(ctl.ctx, command, result)
+	return nil
+}
+
+// Asks for the world to be saved in the background, so that turns carry on while it is saved
+// Saves are written one at a time, so their ImageOutputComplete events are in order. A save asked for while another
+// is being written waits for it, along with any others asked for in the meantime, which share its world and turn.
+func (ctl *controller) requestSave(command Command, kind SaveKind) {
+	if ctl.nextSave == nil {
+		ctl.nextSave = &pendingSave{world: ctl.world, turns: ctl.completedTurns, kind: kind}
+		ctl.worldShared = true
+	}
+	if command.Reply != nil {
+		ctl.nextSave.commands = append(ctl.nextSave.commands, command)
+	}
+	if kind == ManualSave {
+		ctl.nextSave.kind = ManualSave
+	}
+	if !ctl.saving {
+		ctl.startSave()
+	}
+}
+
+// Starts writing the pending save in the background
+func (ctl *controller) startSave() {
+	save := ctl.nextSave
+	ctl.nextSave = nil
+	ctl.saving = true
+	go func() {
+		result := Reply{CompletedTurns: save.turns, Filename: outputName(ctl.fileName, save.turns)}
+		result.Err = writeFile(ctl.ctx, save.world, ctl.fileName, save.turns, ctl.c.ioCommand, ctl.c.ioFileName,
+			ctl.c.ioTurn, ctl.c.ioOutput, ctl.c.ioResult, ctl.c.events)
+		if result.Err == nil {
+			result.Err = ctl.manifest.record(save.turns, result.Filename, save.kind)
+		}
+		for _, command := range save.commands {
+			reply(ctl.ctx, command, result)
+		}
+		ctl.saveDone <- result.Err
+	}()
+}
+
+// Returns true if turns should wait for the saves to catch up, which is when saving every so many turns and a save
+// is already waiting behind the one being written, so that none of these saves are combined and skipped
+func (ctl *controller) savesBehind() bool {
+	return ctl.saveEveryTurns > 0 && ctl.nextSave != nil
+}
+
+// Records that the save being written has finished, and starts the one waiting for it if there is one
+func (ctl *controller) finishSave() {
+	ctl.saving = false
+	if ctl.nextSave != nil {
+		ctl.startSave()
+	}
+}
+
+// Starts a periodic save, unless the world has already been saved periodically at this turn
+func (ctl *controller) savePeriodically() {
+	if ctl.completedTurns == ctl.lastPeriodic {
+		return
+	}
+	ctl.lastPeriodic = ctl.completedTurns
+	ctl.requestSave(Command{Type: SaveCommand}, PeriodicSave)
+}
+
+// Starts calculating the next turn in the background using the workers
+func (ctl *controller) startTurn() {
+	turnDone := make(chan turnResult, 1)
+	go func(world board, turn int) {
+		nextWorld, err := world.next(ctl.ctx, turn)
+		turnDone <- turnResult{nextWorld, err}
+	}(ctl.world, ctl.completedTurns)
+	ctl.turnDone = turnDone
+}
+
+// Replaces the world with the result of the turn that has just finished and reports it
+func (ctl *controller) finishTurn(result turnResult) error {
+	ctl.turnDone = nil
+	if result.err != nil {
+		return result.err
+	}
+	ctl.world = result.world
+	ctl.worldShared = false
+	ctl.completedTurns++
+	ctl.metrics.turnCompleted(ctl.completedTurns)
+	if ctl.world.follow() { // Sent before the TurnComplete, so that the GUI renders the image where it has moved to
+		err := sendEvent(ctl.ctx, ctl.c.events, ViewMoved{
+			CompletedTurns: ctl.completedTurns,
+			Origin:         ctl.world.origin(),
+			Alive:          ctl.world.imageCells(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err := sendEvent(ctl.ctx, ctl.c.events, TurnComplete{
+		CompletedTurns: ctl.completedTurns,
+	})
+	if err != nil {
+		return err
+	}
+	if ctl.saveEveryTurns > 0 && ctl.completedTurns%ctl.saveEveryTurns == 0 {
+		ctl.savePeriodically()
+	}
+	return ctl.flushPending()
+}
+
+// Performs the specified number of turns of the world, handling commands, key presses, cell edits and the ticker
+// between and during turns
+func (ctl *controller) performAllTurns(turns int) error {
+	ctl.saveDone = make(chan error)
+	defer func() { // Never leave a turn or a save running in the background
+		if ctl.busy() {
+			<-ctl.turnDone
+		}
+		if ctl.saving {
+			<-ctl.saveDone
+		}
+		if ctl.nextSave != nil {
+			for _, command := range ctl.nextSave.commands {
+				reply(ctl.ctx, command, Reply{CompletedTurns: ctl.nextSave.turns, Err: errSaveStopped})
+			}
+		}
+	}()
+	twoSecondTicker := time.NewTicker(2 * time.Second)
+	defer twoSecondTicker.Stop()
+	var saveTicks <-chan time.Time
+	if ctl.saveEvery > 0 {
+		saveTicker := time.NewTicker(ctl.saveEvery)
+		defer saveTicker.Stop()
+		saveTicks = saveTicker.C
+	}
+	ctl.nextTurnTime = time.Now()
+	for {
+		finished := ctl.stopping || ctl.completedTurns >= turns
+		if finished && !ctl.busy() && !ctl.saving { // Saves finish first, so their events come before the final ones
+			return nil
+		}
+		var ready <-chan time.Time
+		if !finished && !ctl.busy() && !ctl.savesBehind() {
+			ready = waitForTurn(ctl.paused && ctl.steps == 0, ctl.turnsPerSecond, ctl.nextTurnTime)
+		}
+		var err error
+		select {
+		case <-ctl.ctx.Done():
+			return ctl.ctx.Err()
+		case <-twoSecondTicker.C: // Reports the number of alive cells every 2 seconds
+			aliveCells := ctl.world.count()
+			ctl.metrics.setAliveCells(aliveCells)
+			err = sendEvent(ctl.ctx, ctl.c.events, AliveCellsCount{
+				CompletedTurns: ctl.completedTurns,
+				CellsCount:     aliveCells,
+			})
+		case <-saveTicks:
+			if !finished {
+				ctl.savePeriodically()
+			}
+		case command := <-ctl.c.commands:
+			err = ctl.handle(command)
+		case key := <-ctl.c.keyPresses:
+			if command, ok := keyCommand(key, ctl.paused, ctl.turnsPerSecond); ok {
+				err = ctl.handle(command)
+			}
+		case cells := <-ctl.c.cellEdits:
+			err = ctl.handle(Command{Type: EditCellsCommand, Edits: cells})
+		case <-ready:
+			if ctl.paused {
+				ctl.steps--
+			}
+			if ctl.turnsPerSecond > 0 { // Schedule the next turn, without trying to catch up if this one started late
+				ctl.nextTurnTime = ctl.nextTurnTime.Add(time.Second / time.Duration(ctl.turnsPerSecond))
+				if now := time.Now(); ctl.nextTurnTime.Before(now) {
+					ctl.nextTurnTime = now
+				}
+			}
+			ctl.startTurn()
+		case result := <-ctl.turnDone:
+			err = ctl.finishTurn(result)
+		case err = <-ctl.saveDone:
+			ctl.finishSave()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
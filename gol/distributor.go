@@ -1,6 +1,7 @@
 package gol
 
 import (
+	"context"
 	"strconv"
 	"sync"
 	"time"
@@ -10,44 +11,115 @@ import (
 type distributorChannels struct {
 	events     chan<- Event
 	ioCommand  chan<- ioCommand
-	ioIdle     <-chan bool
+	ioResult   <-chan error
 	ioFileName chan<- string
-	ioOutput   chan<- uint8
-	ioInput    <-chan uint8
+	ioOutput   chan<- []byte
+	ioInput    <-chan []byte
+	commands   <-chan Command
 	keyPresses <-chan rune
+	cellEdits  <-chan []CellEdit
+	ioTurn     chan<- int
+}
+
+// Sends an event unless the run is cancelled first, in which case the reason it was cancelled is returned
+func sendEvent(ctx context.Context, events chan<- Event, event Event) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case events <- event:
+		return nil
+	}
+}
+
+// flipReporter reports the cells that change in a turn in the way the run's FlipMode asks for.
+type flipReporter struct {
+	ctx    context.Context
+	events chan<- Event
+	mode   FlipMode
+	turn   int
+	cells  []util.Cell
+}
+
+// Returns a reporter for the cells that change in the given turn, which reports nothing if events is nil
+func newFlipReporter(ctx context.Context, events chan<- Event, mode FlipMode, turn int) *flipReporter {
+	if events == nil {
+		mode = FlipNone
+	}
+	return &flipReporter{ctx: ctx, events: events, mode: mode, turn: turn}
+}
+
+// Reports that a cell has changed, either straight away or as part of the next batch
+func (f *flipReporter) flip(cell util.Cell) error {
+	switch f.mode {
+	case FlipCells:
+		return sendEvent(f.ctx, f.events, CellFlipped{CompletedTurns: f.turn, Cell: cell})
+	case FlipBatches:
+		f.cells = append(f.cells, cell)
+	}
+	return nil
+}
+
+// Sends the cells changed since the last flush as a single CellsFlipped event, if there are any
+func (f *flipReporter) flush() error {
+	if len(f.cells) == 0 {
+		return nil
+	}
+	err := sendEvent(f.ctx, f.events, CellsFlipped{CompletedTurns: f.turn, Cells: f.cells})
+	f.cells = nil
+	return err
 }
 
 // Sends the file name to io.go so the world can be initialised
-func sendFileName(fileName string, ioCommand chan<- ioCommand, ioFileName chan<- string) {
-	ioCommand <- ioInput
-	ioFileName <- fileName
+func sendFileName(ctx context.Context, fileName string, ioCommand chan<- ioCommand, ioFileName chan<- string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ioCommand <- ioInput:
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ioFileName <- fileName:
+		return nil
+	}
 }
 
-// Returns the world with its initial values filled
-func initialiseWorld(height int, width int, ioInput <-chan uint8, events chan<- Event) [][]byte {
-	world := make([][]byte, height)
-	for y := range world { // Create an array of bytes for each row
-		world[y] = make([]byte, width)
+// Fills the world with its initial values
+// The rows received from the io goroutine are handed to the world, so it must not use them again
+func initialiseWorld(ctx context.Context, world board, ioInput <-chan []byte, ioResult <-chan error,
+	events chan<- Event, flips FlipMode) error {
+	reporter := newFlipReporter(ctx, events, flips, 0)
+	for y := 0; y < world.height(); y++ { // Receive each row of the world
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-ioResult: // The io goroutine only reports a result before the last row if it failed
+			return err
+		case row := <-ioInput:
+			world.setRow(y, row)
+		}
 	}
-	for y, row := range world {
-		for x := range row {
-			cell := <-ioInput
-			world[y][x] = cell // Add each cell to the row
-			if cell == 255 { // If the cell is alive send a cellFlipped event
-				events <- CellFlipped{
-					CompletedTurns: 0,
-					Cell: util.Cell{
-						X: x,
-						Y: y,
-					},
-				}
-			}
+	for _, cell := range world.alive() { // Report each alive cell as flipped
+		err := reporter.flip(cell)
+		if err != nil {
+			return err
 		}
 	}
-	events <- TurnComplete{
-		CompletedTurns: 0,
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-ioResult:
+		if err != nil {
+			return err
+		}
 	}
-	return world
+	err := reporter.flush()
+	if err != nil {
+		return err
+	}
+	return sendEvent(ctx, events, TurnComplete{
+		CompletedTurns: 0,
+	})
 }
 
 // Returns a slice of channels, that will each be used to communicate a section of the world between the distributor and a worker
@@ -92,14 +164,16 @@ func calcStartYValues(sectionHeights []int) []int {
 func getNeighbours(world [][]byte, row int, column int) []byte {
 	rowAbove, rowBelow := row - 1, row + 1
 	if row == 0 {
-		rowAbove = len(world[0]) - 1
-	} else if row == len(world[0]) - 1 {
+		rowAbove = len(world) - 1
+	}
+	if row == len(world) - 1 {
 		rowBelow = 0
 	}
-	columnLeft, columnRight := column - 1, column + 1
+	columnLeft, columnRight := column - 1, column + 1 // A world 1 cell wide is both the first and last column
 	if column == 0 {
 		columnLeft = len(world[0]) - 1
-	} else if column == len(world[0]) - 1 {
+	}
+	if column == len(world[0]) - 1 {
 		columnRight = 0
 	}
 	neighbours := []byte{world[rowAbove][columnLeft], world[rowAbove][column], world[rowAbove][columnRight],
@@ -134,8 +208,13 @@ func calcValue(item byte, liveNeighbours int) byte {
 	return calculatedValue
 }
 
-// Returns the next state of part of a world given the current state
-func calcNextState(world [][]byte, events chan<- Event, startY int, turn int) [][]byte {
+// Returns the next state of part of a world given the current state, using the given kernel
+func calcNextState(ctx context.Context, world [][]byte, events chan<- Event, flips FlipMode, startY int,
+	turn int, kernel Kernel) ([][]byte, error) {
+	reporter := newFlipReporter(ctx, events, flips, turn)
+	if kernel != NaiveKernel {
+		return calcNextStateWith(kernel, reporter, world, startY)
+	}
 	var nextWorld [][]byte
 	for y, row := range world[1:len(world) - 1] { // Loops over each row apart from the top and bottom row
 		nextWorld = append(nextWorld, []byte{})
@@ -144,108 +223,102 @@ func calcNextState(world [][]byte, events chan<- Event, startY int, turn int) []
 			liveNeighbours := calcLiveNeighbours(neighbours)
 			value := calcValue(element, liveNeighbours)
 			nextWorld[y] = append(nextWorld[y], value)
-			if value != world[y + 1][x] { // If the value of the cell has changed send a cell flipped event
-				events <- CellFlipped{
-					CompletedTurns: turn,
-					Cell: util.Cell{
-						X: x,
-						Y: y + startY,
-					},
+			if value != world[y + 1][x] { // If the value of the cell has changed report it as flipped
+				err := reporter.flip(util.Cell{
+					X: x,
+					Y: y + startY,
+				})
+				if err != nil {
+					return nil, err
 				}
 			}
 		}
 	}
-	return nextWorld
+	return nextWorld, reporter.flush() // Batched flips are sent once the whole part has been calculated
 }
 
 // Takes part of an image, calculates the next stage, and passes it back
-func worker(part chan [][]byte, events chan<- Event, startY int, turns int) {
+// The time spent calculating is added to the metrics of the worker with the given id
+func worker(ctx context.Context, part chan [][]byte, events chan<- Event, flips FlipMode, startY int, turns int,
+	kernel Kernel, metrics *Metrics, id int) {
 	for turn := 0; turn < turns; turn++ {
-		thePart := <-part
-		nextPart := calcNextState(thePart, events, startY, turn)
-		part <- nextPart
+		var thePart [][]byte
+		select {
+		case <-ctx.Done():
+			return
+		case thePart = <-part:
+		}
+		started := time.Now()
+		nextPart, err := calcNextState(ctx, thePart, events, flips, startY, turn, kernel)
+		metrics.addWorkerTime(id, time.Since(started))
+		if err != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case part <- nextPart:
+		}
 	}
 }
 
-// Reports the number of alive cells every 2 seconds
-func ticker(twoSecondTicker *time.Ticker, mutexTurnsWorld *sync.Mutex, completedTurns *int, world *[][]byte, events chan<- Event) {
-	for {
-		<-twoSecondTicker.C
-		mutexTurnsWorld.Lock()
-		events <- AliveCellsCount{
-			CompletedTurns: *completedTurns,
-			CellsCount:     calcNumAliveCells(*world),
+// Returns the speed the user asked for after pressing '+', '-' or 'm', where 0 turns per second means no limit
+func changeSpeed(turnsPerSecond int, key rune) int {
+	switch key {
+	case '+', '=':
+		if turnsPerSecond == 0 || turnsPerSecond >= maxTurnsPerSecond {
+			return 0
 		}
-		mutexTurnsWorld.Unlock()
+		return turnsPerSecond * 2
+	case '-':
+		if turnsPerSecond == 0 || turnsPerSecond > maxTurnsPerSecond {
+			return maxTurnsPerSecond
+		} else if turnsPerSecond <= 1 {
+			return 1
+		}
+		return turnsPerSecond / 2
 	}
+	return 0
 }
 
-// Receives key presses from the user and performs the appropriate action
-func handleKeyPresses(keyPresses <-chan rune, mutexTurnsWorld *sync.Mutex, world *[][]byte, fileName string,
-	completedTurns *int, ioCommand chan<- ioCommand, ioFileName chan<- string, ioOutput chan<- uint8,
-	events chan<- Event, stop chan<- bool, pause chan<- bool) {
-	paused := false
-	for {
-		key := <-keyPresses
-		switch key {
-		case 115: // Save
-			mutexTurnsWorld.Lock()
-			writeFile(*world, fileName, *completedTurns, ioCommand, ioFileName, ioOutput, events)
-			mutexTurnsWorld.Unlock()
-		case 113: // Stop
-			stop <- true
-		case 112: // Pause/Resume
-			pause <- true
-			var newState State
-			if paused {
-				newState = Continuing
-				paused = false
-			} else {
-				newState = Paused
-				paused = true
-			}
-			mutexTurnsWorld.Lock()
-			events <- StateChange{*completedTurns, newState}
-			mutexTurnsWorld.Unlock()
+// Returns a channel that fires when the next turn may start, or nil if it should wait for a key press
+func waitForTurn(paused bool, turnsPerSecond int, nextTurnTime time.Time) <-chan time.Time {
+	if paused {
+		return nil
+	}
+	if turnsPerSecond > 0 {
+		if wait := time.Until(nextTurnTime); wait > 0 {
+			return time.After(wait)
 		}
 	}
+	ready := make(chan time.Time, 1)
+	ready <- nextTurnTime
+	return ready
 }
 
-// Performs the specified number of turns of the world
-func performAllTurns(turns int, stop <-chan bool, pause <-chan bool, parts []chan [][]byte, startYValues []int,
-	sectionHeights []int, world *[][]byte, threads int, mutexTurnsWorld *sync.Mutex, completedTurns *int, events chan<- Event) {
-	// For each turn, pass part of the board to each worker, process it, then put it back together and repeat
-	turnsLoop:
-		for turn := 0; turn < turns; turn++ {
-			select {
-			case <-stop:
-				break turnsLoop
-			case <-pause:
-				select {
-				case <-stop:
-					break turnsLoop
-				case <-pause:
-				}
-			default: // If no keys have been pressed just move onto performing the next turn of the world
-			}
-			for i, part := range parts { // Send the next part to each worker
-				startY := startYValues[i]
-				endY := startY + sectionHeights[i]
-				worldPart := getPart(*world, threads, i, startY, endY)
-				part <- worldPart
-			}
-			var nextWorld [][]byte
-			for _, part := range parts { // Collect each part from each worker and build the next state of the world
-				nextWorld = append(nextWorld, <-part...)
-			}
-			mutexTurnsWorld.Lock()
-			*world = nextWorld
-			*completedTurns = turn + 1 // turn + 1 because we are at the end of the turn (e.g. end of turn 0 means completed 1 turn)
-			mutexTurnsWorld.Unlock()
-			events <- TurnComplete{
-				CompletedTurns: *completedTurns,
-			}
+// Passes part of the world to each worker and puts the parts they send back together into the next world
+func calcNextWorld(ctx context.Context, parts []chan [][]byte, startYValues []int, sectionHeights []int,
+	world [][]byte, threads int) ([][]byte, error) {
+	for i, part := range parts { // Send the next part to each worker
+		startY := startYValues[i]
+		endY := startY + sectionHeights[i]
+		worldPart := getPart(world, threads, i, startY, endY)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case part <- worldPart:
+		}
+	}
+	var nextWorld [][]byte
+	for _, part := range parts { // Collect each part from each worker and build the next state of the world
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case nextPart := <-part:
+			nextWorld = append(nextWorld, nextPart...)
+		}
 	}
+	return nextWorld, nil
 }
 
 // Returns the number of alive cells in a world
@@ -295,55 +368,134 @@ func getAliveCells(world [][]byte) []util.Cell {
 	return aliveCells
 }
 
-// Writes to a file and sends the correct event
-func writeFile(world [][]byte, fileName string, turns int, ioCommand chan<- ioCommand, ioFileName chan<- string,
-	ioOutputChannel chan<- uint8, events chan<- Event) {
-	outputFileName := fileName + "x" + strconv.Itoa(turns)
-	ioCommand <- ioOutput
-	ioFileName <- outputFileName
-	for _, row := range world {
-		for _, element := range row {
-			ioOutputChannel <- element
+// Returns the name of the image a world is saved to after the given number of turns
+func outputName(fileName string, turns int) string {
+	return fileName + "x" + strconv.Itoa(turns)
+}
+
+// Writes to a file and sends the correct event once the io goroutine has finished writing it
+// The rows of the world are handed to the io goroutine, so they must not change until the file has been written
+func writeFile(ctx context.Context, world board, fileName string, turns int, ioCommand chan<- ioCommand,
+	ioFileName chan<- string, ioTurn chan<- int, ioOutputChannel chan<- []byte, ioResult <-chan error,
+	events chan<- Event) error {
+	outputFileName := outputName(fileName, turns)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ioCommand <- ioOutput:
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ioFileName <- outputFileName:
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ioTurn <- turns:
+	}
+	for y := 0; y < world.height(); y++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ioOutputChannel <- world.row(y):
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-ioResult:
+		if err != nil {
+			return err
 		}
 	}
-	events <- ImageOutputComplete{ // implements Event
+	return sendEvent(ctx, events, ImageOutputComplete{ // implements Event
 		CompletedTurns: turns,
 		Filename:       outputFileName,
-	}
+	})
 }
 
 // Distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels) {
+// Shutting down happens in a fixed order so that nothing is leaked and no events are sent after the final ones:
+// once all turns have been performed (or the user quits) the turn in progress is finished and the workers are
+// stopped and waited for, and only then is the final output written.
+func distributor(ctx context.Context, p Params, c distributorChannels) error {
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	workers := &sync.WaitGroup{}
+	defer workers.Wait()
+	defer stopWorkers() // Stops the workers however the distributor returns
+
 	fileName := strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(p.ImageHeight)
-	sendFileName(fileName, c.ioCommand, c.ioFileName)
-	world := initialiseWorld(p.ImageHeight, p.ImageWidth, c.ioInput, c.events)
-	parts := createPartChannels(p.Threads)
-	sectionHeights := calcSectionHeights(p.ImageHeight, p.Threads)
-	startYValues := calcStartYValues(sectionHeights)
-	for i, part := range parts { // Starts the workers ready to receive parts to calculate the next state
-		go worker(part, c.events, startYValues[i], p.Turns)
-	}
-	var completedTurns int
-	mutexTurnsWorld := &sync.Mutex{}
-	twoSecondTicker := time.NewTicker(2 * time.Second)
-	go ticker(twoSecondTicker, mutexTurnsWorld, &completedTurns, &world, c.events) // Runs the ticker
-	stop := make(chan bool)
-	pause := make(chan bool)
-	go handleKeyPresses(c.keyPresses, mutexTurnsWorld, &world, fileName, &completedTurns, c.ioCommand, c.ioFileName,
-		c.ioOutput, c.events, stop, pause) // Handles key presses for the user
-	performAllTurns(p.Turns, stop, pause, parts, startYValues, sectionHeights, &world, p.Threads, mutexTurnsWorld,
-		&completedTurns, c.events)
-	twoSecondTicker.Stop() // The ticker stops running once all turns have been performed
-	mutexTurnsWorld.Lock()
-	aliveCells := getAliveCells(world)
-	c.events <- FinalTurnComplete{ // Send a final turn complete event to the events channel
-		CompletedTurns: completedTurns,
+	err := sendFileName(ctx, fileName, c.ioCommand, c.ioFileName)
+	if err != nil {
+		return err
+	}
+	var world board
+	if p.Backend == SparseBackend || p.InfinitePlane { // The sparse backend starts its workers for each turn
+		world = newSparseBoard(p, c.events)
+	} else if p.TileSize > 0 {
+		tiles := newTiles(p.TileSize, p.Threads)
+		for i := 0; i < p.Workers(); i++ { // Starts the workers ready to take tiles as they are handed out
+			workers.Add(1)
+			go func(id int) {
+				defer workers.Done()
+				tileWorker(workersCtx, tiles.jobs, p.TileSize, c.events, p.FlipEvents, p.Kernel, p.Metrics, id)
+			}(i)
+		}
+		world = newDenseBoard(p.ImageHeight, nil, tiles)
+	} else {
+		strips := newStrips(p.ImageHeight, p.Threads)
+		for i, part := range strips.parts { // Starts the workers ready to receive parts to calculate the next state
+			workers.Add(1)
+			go func(part chan [][]byte, startY int, id int) {
+				defer workers.Done()
+				worker(workersCtx, part, c.events, p.FlipEvents, startY, p.Turns, p.Kernel, p.Metrics, id)
+			}(part, strips.startYValues[i], i)
+		}
+		world = newDenseBoard(p.ImageHeight, strips, nil)
+	}
+	err = initialiseWorld(ctx, world, c.ioInput, c.ioResult, c.events, p.FlipEvents)
+	if err != nil {
+		return err
+	}
+	p.Metrics.setAliveCells(world.count())
+	ctl := &controller{
+		ctx:            ctx,
+		c:              c,
+		fileName:       fileName,
+		world:          world,
+		turnsPerSecond: p.TurnsPerSecond,
+		flips:          p.FlipEvents,
+		metrics:        p.Metrics,
+		writeImage:     true,
+		saveEveryTurns: p.SaveEveryTurns,
+		saveEvery:      p.SaveEvery,
+		manifest:       newManifest(p, fileName),
+	}
+	err = ctl.performAllTurns(p.Turns)
+	stopWorkers()
+	workers.Wait()
+	if err != nil {
+		return err
+	}
+	aliveCells := ctl.world.alive()
+	p.Metrics.setAliveCells(len(aliveCells))
+	err = sendEvent(ctx, c.events, FinalTurnComplete{ // Send a final turn complete event to the events channel
+		CompletedTurns: ctl.completedTurns,
 		Alive:          aliveCells,
+	})
+	if err != nil {
+		return err
+	}
+	if ctl.writeImage {
+		err = writeFile(ctx, ctl.world, fileName, ctl.completedTurns, c.ioCommand, c.ioFileName, c.ioTurn,
+			c.ioOutput, c.ioResult, c.events)
+		if err == nil {
+			err = ctl.manifest.record(ctl.completedTurns, outputName(fileName, ctl.completedTurns), FinalSave)
+		}
+		if err != nil {
+			return err
+		}
 	}
-	writeFile(world, fileName, completedTurns, c.ioCommand, c.ioFileName, c.ioOutput, c.events)
-	c.ioCommand <- ioCheckIdle // Make sure that the Io has finished any output before exiting.
-	<-c.ioIdle
-	c.events <- StateChange{completedTurns, Quitting}
-	mutexTurnsWorld.Unlock()
-	close(c.events) // Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+	return sendEvent(ctx, c.events, StateChange{ctl.completedTurns, Quitting})
 }
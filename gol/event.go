@@ -45,6 +45,14 @@ type StateChange struct { // implements Event
 	NewState       State
 }
 
+// SpeedChange is an Event notifying the user that the speed limit has been changed.
+// This Event should be sent every time the speed is changed with a key press.
+// A TurnsPerSecond of 0 means turns are performed as fast as possible.
+type SpeedChange struct { // implements Event
+	CompletedTurns int
+	TurnsPerSecond int
+}
+
 // CellFlipped is an Event notifying the GUI about a change of state of a single cell.
 // This even should be sent every time a cell changes state.
 // Make sure to send this event for all cells that are alive when the image is loaded in.
@@ -53,6 +61,30 @@ type CellFlipped struct { // implements Event
 	Cell           util.Cell
 }
 
+// CellsFlipped is an Event notifying the GUI about a change of state of many cells at once.
+// It stands for a CellFlipped event for each of the cells, sent in the same turn.
+type CellsFlipped struct { // implements Event
+	CompletedTurns int
+	Cells          []util.Cell
+}
+
+// ViewMoved is an Event notifying the GUI that the image has moved to follow the population of an infinite plane.
+// Origin is where the top left cell of the image now is on the plane, and Alive holds every alive cell within the
+// image, in the coordinates of the image like the cells of every other event apart from FinalTurnComplete.
+// It is sent after the flips of a turn and before its TurnComplete, replacing the cells shown rather than flipping
+// them.
+type ViewMoved struct { // implements Event
+	CompletedTurns int
+	Origin         util.Cell
+	Alive          []util.Cell
+}
+
+// CellsEdited is an Event notifying the GUI that cells have been edited between turns, so that it renders them.
+// It is sent after the flips of the edit. Unlike TurnComplete no turn has been performed.
+type CellsEdited struct { // implements Event
+	CompletedTurns int
+}
+
 // TurnComplete is an Event notifying the GUI about turn completion.
 // SDL will render a frame when this event is sent.
 // All CellFlipped events must be sent *before* TurnComplete.
@@ -62,6 +94,7 @@ type TurnComplete struct { // implements Event
 
 // FinalTurnComplete is an Event notifying the testing framework about the new world state after execution finished.
 // The data included with this Event is used directly by the tests.
+// On an infinite plane Alive holds every alive cell in the coordinates of the plane, wherever the image is.
 // SDL ignores this Event.
 type FinalTurnComplete struct {
 	CompletedTurns int
@@ -93,6 +126,17 @@ func (event StateChange) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event SpeedChange) String() string {
+	if event.TurnsPerSecond == 0 {
+		return "Speed unlimited"
+	}
+	return fmt.Sprintf("Speed %v turns/s", event.TurnsPerSecond)
+}
+
+func (event SpeedChange) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event AliveCellsCount) String() string {
 	return fmt.Sprintf("Alive Cells %v", event.CellsCount)
 }
@@ -117,6 +161,30 @@ func (event CellFlipped) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CellsFlipped) String() string {
+	return ""
+}
+
+func (event CellsFlipped) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event ViewMoved) String() string {
+	return ""
+}
+
+func (event ViewMoved) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event CellsEdited) String() string {
+	return ""
+}
+
+func (event CellsEdited) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event TurnComplete) String() string {
 	return fmt.Sprintf("")
 }
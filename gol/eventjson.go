@@ -0,0 +1,174 @@
+package gol
+
+import (
+	"encoding/json"
+	"fmt"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// eventJSON is the JSON encoding shared by every event. Type names the event and Turn is its completed turns, the
+// other fields are only present for the events that have them. Cells are encoded as [x, y] pairs.
+type eventJSON struct {
+	Type           string    `json:"type"`
+	Turn           int       `json:"turn"`
+	CellsCount     *int      `json:"cellsCount,omitempty"`
+	Filename       string    `json:"filename,omitempty"`
+	State          *State    `json:"state,omitempty"`
+	TurnsPerSecond *int      `json:"turnsPerSecond,omitempty"`
+	Cell           *[2]int   `json:"cell,omitempty"`
+	Cells          [][2]int  `json:"cells,omitempty"`
+	Origin         *[2]int   `json:"origin,omitempty"`
+	AliveCount     *int      `json:"aliveCount,omitempty"`
+	Alive          *[][2]int `json:"alive,omitempty"`
+}
+
+// Returns the cells as [x, y] pairs
+func encodeCells(cells []util.Cell) [][2]int {
+	pairs := make([][2]int, len(cells))
+	for i, cell := range cells {
+		pairs[i] = [2]int{cell.X, cell.Y}
+	}
+	return pairs
+}
+
+// Returns the cells encoded as [x, y] pairs
+func decodeCells(pairs [][2]int) []util.Cell {
+	if pairs == nil {
+		return nil
+	}
+	cells := make([]util.Cell, len(pairs))
+	for i, pair := range pairs {
+		cells[i] = util.Cell{X: pair[0], Y: pair[1]}
+	}
+	return cells
+}
+
+// MarshalJSON encodes the state by name, so that the encoding does not depend on the order of the constants.
+func (state State) MarshalJSON() ([]byte, error) {
+	switch state {
+	case Paused, Executing, Quitting, Continuing:
+		return json.Marshal(state.String())
+	}
+	return nil, fmt.Errorf("gol: cannot encode unknown state %d", int(state))
+}
+
+// UnmarshalJSON decodes a state encoded by MarshalJSON.
+func (state *State) UnmarshalJSON(data []byte) error {
+	var name string
+	err := json.Unmarshal(data, &name)
+	if err != nil {
+		return err
+	}
+	for _, s := range []State{Paused, Executing, Quitting, Continuing} {
+		if s.String() == name {
+			*state = s
+			return nil
+		}
+	}
+	return fmt.Errorf("gol: unknown state %q", name)
+}
+
+func (event AliveCellsCount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{Type: "AliveCellsCount", Turn: event.CompletedTurns, CellsCount: &event.CellsCount})
+}
+
+func (event ImageOutputComplete) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{Type: "ImageOutputComplete", Turn: event.CompletedTurns, Filename: event.Filename})
+}
+
+func (event StateChange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{Type: "StateChange", Turn: event.CompletedTurns, State: &event.NewState})
+}
+
+func (event SpeedChange) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{Type: "SpeedChange", Turn: event.CompletedTurns,
+		TurnsPerSecond: &event.TurnsPerSecond})
+}
+
+func (event CellFlipped) MarshalJSON() ([]byte, error) {
+	cell := [2]int{event.Cell.X, event.Cell.Y}
+	return json.Marshal(eventJSON{Type: "CellFlipped", Turn: event.CompletedTurns, Cell: &cell})
+}
+
+func (event CellsFlipped) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{Type: "CellsFlipped", Turn: event.CompletedTurns, Cells: encodeCells(event.Cells)})
+}
+
+func (event ViewMoved) MarshalJSON() ([]byte, error) {
+	origin := [2]int{event.Origin.X, event.Origin.Y}
+	alive := encodeCells(event.Alive)
+	return json.Marshal(eventJSON{Type: "ViewMoved", Turn: event.CompletedTurns, Origin: &origin, Alive: &alive})
+}
+
+func (event CellsEdited) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{Type: "CellsEdited", Turn: event.CompletedTurns})
+}
+
+func (event TurnComplete) MarshalJSON() ([]byte, error) {
+	return json.Marshal(eventJSON{Type: "TurnComplete", Turn: event.CompletedTurns})
+}
+
+// MarshalJSON encodes the number of alive cells as well as the cells themselves, so that a summary can leave the
+// cells out without losing the count.
+func (event FinalTurnComplete) MarshalJSON() ([]byte, error) {
+	aliveCount := len(event.Alive)
+	alive := encodeCells(event.Alive)
+	return json.Marshal(eventJSON{Type: "FinalTurnComplete", Turn: event.CompletedTurns, AliveCount: &aliveCount,
+		Alive: &alive})
+}
+
+// UnmarshalEvent decodes an event encoded as JSON by its MarshalJSON method.
+// A FinalTurnComplete without its alive cells, such as a summary, is decoded with Alive left nil.
+func UnmarshalEvent(data []byte) (Event, error) {
+	var e eventJSON
+	err := json.Unmarshal(data, &e)
+	if err != nil {
+		return nil, err
+	}
+	missing := func(field string) (Event, error) {
+		return nil, fmt.Errorf("gol: %v event is missing %v", e.Type, field)
+	}
+	switch e.Type {
+	case "AliveCellsCount":
+		if e.CellsCount == nil {
+			return missing("cellsCount")
+		}
+		return AliveCellsCount{CompletedTurns: e.Turn, CellsCount: *e.CellsCount}, nil
+	case "ImageOutputComplete":
+		return ImageOutputComplete{CompletedTurns: e.Turn, Filename: e.Filename}, nil
+	case "StateChange":
+		if e.State == nil {
+			return missing("state")
+		}
+		return StateChange{CompletedTurns: e.Turn, NewState: *e.State}, nil
+	case "SpeedChange":
+		if e.TurnsPerSecond == nil {
+			return missing("turnsPerSecond")
+		}
+		return SpeedChange{CompletedTurns: e.Turn, TurnsPerSecond: *e.TurnsPerSecond}, nil
+	case "CellFlipped":
+		if e.Cell == nil {
+			return missing("cell")
+		}
+		return CellFlipped{CompletedTurns: e.Turn, Cell: util.Cell{X: e.Cell[0], Y: e.Cell[1]}}, nil
+	case "CellsFlipped":
+		return CellsFlipped{CompletedTurns: e.Turn, Cells: decodeCells(e.Cells)}, nil
+	case "ViewMoved":
+		if e.Origin == nil || e.Alive == nil {
+			return missing("origin and alive")
+		}
+		origin := util.Cell{X: e.Origin[0], Y: e.Origin[1]}
+		return ViewMoved{CompletedTurns: e.Turn, Origin: origin, Alive: decodeCells(*e.Alive)}, nil
+	case "CellsEdited":
+		return CellsEdited{CompletedTurns: e.Turn}, nil
+	case "TurnComplete":
+		return TurnComplete{CompletedTurns: e.Turn}, nil
+	case "FinalTurnComplete":
+		event := FinalTurnComplete{CompletedTurns: e.Turn}
+		if e.Alive != nil {
+			event.Alive = decodeCells(*e.Alive)
+		}
+		return event, nil
+	}
+	return nil, fmt.Errorf("gol: unknown event type %q", e.Type)
+}
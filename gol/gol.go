@@ -1,39 +1,237 @@
 package gol
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	// TurnsPerSecond limits how fast turns are performed, 0 means as fast as possible.
+	// It can be changed while running with the '+', '-' and 'm' keys.
+	TurnsPerSecond int
+	// FlipEvents chooses how the cells that change each turn are reported, by default with a CellFlipped each.
+	FlipEvents FlipMode
+	// Metrics, if not nil, is updated with measurements of the run as it happens.
+	Metrics *Metrics
+	// InitialCells, if not nil, are the cells alive at the start of the run instead of those in the image in images.
+	InitialCells []util.Cell
+	// InputDir is the directory images are read from, images by default.
+	InputDir string
+	// OutputDir is the directory images are written to, out by default.
+	OutputDir string
+	// ImageFormat is the format images are read and written in, PGM by default.
+	ImageFormat ImageFormat
+	// SaveEveryTurns and SaveEvery, when above 0, write an image every so many turns or so often, leaving a trail of
+	// images along with a manifest of them. KeepSaves limits how many of these images are kept, 0 keeps them all.
+	// Turns wait for the saves every SaveEveryTurns if they fall behind, while those every SaveEvery are combined.
+	SaveEveryTurns int
+	SaveEvery      time.Duration
+	KeepSaves      int
+	// Backend chooses how the world is stored and calculated, by default with a byte for every cell.
+	Backend Backend
+	// Kernel chooses how the dense backend calculates the next state of each cell, by default with a lookup table.
+	Kernel Kernel
+	// TileSize, when above 0, splits the world of the dense backend into tiles of TileSize x TileSize cells, which
+	// the workers take one at a time as they become free, instead of into a strip of rows for each worker.
+	TileSize int
+	// InfinitePlane lets cells live beyond the edges of the image instead of wrapping around them, always using the
+	// sparse backend. The image follows the population as it spreads, sending a ViewMoved whenever it moves, and
+	// images and flips only hold the cells within it, while FinalTurnComplete and AliveCellsCount include every cell.
+	InfinitePlane bool
+}
+
+// FlipMode is how the cells that change are reported to the user.
+type FlipMode int
+
+const (
+	// FlipCells sends a CellFlipped event for every cell that changes.
+	FlipCells FlipMode = iota
+	// FlipBatches sends a single CellsFlipped event for the cells each worker changes in a turn.
+	FlipBatches
+	// FlipNone does not report changed cells at all, which is the fastest option for runs without a display.
+	FlipNone
+)
+
+// ImageFormat is the file format of the images read and written by the io goroutine.
+type ImageFormat int
+
+const (
+	// PGMFormat images are binary PGM files with a byte for every cell, ending in .pgm.
+	PGMFormat ImageFormat = iota
+	// WorldFormat images are compressed world files with a bit for every cell, ending in .world, which are much
+	// smaller for very large boards. See util.WriteWorld.
+	WorldFormat
+)
+
+// Backend is the way the world of a run is stored and calculated.
+type Backend int
+
+const (
+	// DenseBackend stores a byte for every cell and splits the world into strips, one for each worker.
+	DenseBackend Backend = iota
+	// SparseBackend stores only the alive cells, so huge worlds that are mostly empty take little time and memory.
+	// The rows next to alive cells are split between the workers each turn.
+	SparseBackend
+)
+
+// maxTurnsPerSecond is the fastest limited speed, doubling it from here removes the limit altogether.
+const maxTurnsPerSecond = 1024
+
+// CellEdit asks the distributor to set a single cell to be alive or dead between turns.
+type CellEdit struct {
+	Cell  util.Cell
+	Alive bool
+}
+
+// Validate returns a descriptive error for params that cannot be run, such as a world smaller than 1x1 or fewer
+// than one thread. More threads than there are rows are allowed, but only as many workers as rows are started.
+func (p Params) Validate() error {
+	switch {
+	case p.ImageWidth <= 0 || p.ImageHeight <= 0:
+		return fmt.Errorf("gol: the world must be at least 1x1, not %vx%v", p.ImageWidth, p.ImageHeight)
+	case p.Threads <= 0:
+		return fmt.Errorf("gol: at least one thread is needed, not %v", p.Threads)
+	case p.Turns < 0:
+		return fmt.Errorf("gol: turns cannot be negative, not %v", p.Turns)
+	case p.TurnsPerSecond < 0:
+		return fmt.Errorf("gol: turns per second cannot be negative, not %v", p.TurnsPerSecond)
+	case p.SaveEveryTurns < 0 || p.SaveEvery < 0 || p.KeepSaves < 0:
+		return errors.New("gol: automatic saves cannot be set to a negative number of turns, time or images")
+	case p.TileSize < 0:
+		return fmt.Errorf("gol: tile size cannot be negative, not %v", p.TileSize)
+	case p.FlipEvents < FlipCells || p.FlipEvents > FlipNone:
+		return fmt.Errorf("gol: unknown flip mode %v", p.FlipEvents)
+	case p.ImageFormat < PGMFormat || p.ImageFormat > WorldFormat:
+		return fmt.Errorf("gol: unknown image format %v", p.ImageFormat)
+	case p.Backend < DenseBackend || p.Backend > SparseBackend:
+		return fmt.Errorf("gol: unknown backend %v", p.Backend)
+	case p.Kernel < LookupKernel || p.Kernel > NaiveKernel:
+		return fmt.Errorf("gol: unknown kernel %v", p.Kernel)
+	}
+	return nil
+}
+
+// Workers returns the number of workers a run with these params keeps busy, which is never more than Threads.
+// Strips of rows need at least one row each and tiles are handed out one to a worker, so small worlds need fewer.
+func (p Params) Workers() int {
+	switch {
+	case p.Backend == SparseBackend || p.InfinitePlane:
+		return p.Threads
+	case p.TileSize > 0:
+		across := (p.ImageWidth + p.TileSize - 1) / p.TileSize
+		down := (p.ImageHeight + p.TileSize - 1) / p.TileSize
+		return minInt(p.Threads, across*down)
+	}
+	return minInt(p.Threads, p.ImageHeight)
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
-func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+// Params that are not valid, see Params.Validate, are returned as an error straight away, without starting anything
+// or sending any events, and the events channel is closed.
+// Every goroutine it starts has stopped by the time the events channel is closed, and the final FinalTurnComplete,
+// ImageOutputComplete (unless the run was shut down) and Quitting events are always the last ones sent.
+// Saves are written in the background while turns carry on, one at a time in the order they were asked for, and
+// each is followed by an ImageOutputComplete once it has been written. Saves asked for while another is being written
+// are combined into one of the world at the turn the first of them was asked for.
+// Key presses are turned into the equivalent Command: 's' saves, 'q' quits, 'k' shuts down, 'p' pauses and resumes,
+// 'n' steps while paused and '+', '-' and 'm' change the speed.
+//
+// Deprecated: an error once the run has started, such as an image that cannot be read or written, panics in a
+// goroutine Run cannot recover from. Use RunContext, which returns it.
+func Run(p Params, events chan<- Event, keyPresses <-chan rune) error {
+	return RunEditable(p, events, keyPresses, nil)
+}
+
+// RunEditable is Run with an extra channel of cell edits, which are applied to the world between turns.
+// A CellFlipped event is sent for every cell an edit changes, followed by a CellsEdited so the GUI redraws.
+//
+// Deprecated: like Run, an error once the run has started panics. Use RunEditableContext, which returns it.
+func RunEditable(p Params, events chan<- Event, keyPresses <-chan rune, cellEdits <-chan []CellEdit) error {
+	err := p.Validate()
+	if err != nil {
+		close(events)
+		return err
+	}
+	go func() {
+		util.Check(run(context.Background(), p, events, nil, keyPresses, cellEdits))
+	}()
+	return nil
+}
+
+// RunContext processes the Game of Life like Run, but blocks until the run has finished and returns any error.
+// Cancelling ctx stops every goroutine started for the run, after which ctx.Err() is returned.
+// The events channel is closed before RunContext returns, so it must be drained by another goroutine.
+func RunContext(ctx context.Context, p Params, events chan<- Event, keyPresses <-chan rune) error {
+	return run(ctx, p, events, nil, keyPresses, nil)
+}
+
+// RunEditableContext processes the Game of Life like RunContext, with the extra channel of cell edits RunEditable
+// takes.
+func RunEditableContext(ctx context.Context, p Params, events chan<- Event, keyPresses <-chan rune,
+	cellEdits <-chan []CellEdit) error {
+	return run(ctx, p, events, nil, keyPresses, cellEdits)
+}
+
+// RunCommands processes the Game of Life like RunContext, but is controlled by commands instead of key presses.
+// Each command is carried out between turns, and replied to on its Reply channel if it has one.
+func RunCommands(ctx context.Context, p Params, events chan<- Event, commands <-chan Command) error {
+	return run(ctx, p, events, commands, nil, nil)
+}
+
+// Starts the distributor and io goroutines, then waits for both to finish before closing events
+func run(ctx context.Context, p Params, events chan<- Event, commands <-chan Command, keyPresses <-chan rune,
+	cellEdits <-chan []CellEdit) error {
+	defer close(events) // Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+	err := p.Validate()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p.Metrics.start(events, p.Workers())
 
 	ioCommand := make(chan ioCommand)
-	ioIdle := make(chan bool)
+	ioResult := make(chan error)
 	ioFileName := make(chan string)
-	ioOutput := make(chan uint8)
-	ioInput := make(chan uint8)
+	ioTurn := make(chan int)
+	ioOutput := make(chan []byte)
+	ioInput := make(chan []byte)
+
+	ioChannels := ioChannels{
+		command:  ioCommand,
+		result:   ioResult,
+		filename: ioFileName,
+		turn:     ioTurn,
+		output:   ioOutput,
+		input:    ioInput,
+	}
+	ioFinished := make(chan bool)
+	go func() {
+		startIo(ctx, p, ioChannels)
+		close(ioFinished)
+	}()
 
 	distributorChannels := distributorChannels{
 		events,
 		ioCommand,
-		ioIdle,
+		ioResult,
 		ioFileName,
 		ioOutput,
 		ioInput,
+		commands,
 		keyPresses,
+		cellEdits,
+		ioTurn,
 	}
-	go distributor(p, distributorChannels)
-
-	ioChannels := ioChannels{
-		command:  ioCommand,
-		idle:     ioIdle,
-		filename: ioFileName,
-		output:   ioOutput,
-		input:    ioInput,
-	}
-	go startIo(p, ioChannels)
+	err = distributor(ctx, p, distributorChannels)
+	cancel() // The io goroutine runs until it is cancelled
+	<-ioFinished
+	return err
 }
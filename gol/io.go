@@ -1,22 +1,28 @@
 package gol
 
 import (
+	"bufio"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
 type ioChannels struct {
-	command <-chan ioCommand
-	idle    chan<- bool
+	command  <-chan ioCommand
+	result   chan<- error
 	filename <-chan string
-	output   <-chan uint8
-	input    chan<- uint8
+	turn     <-chan int    // The turn an image being written is of
+	output   <-chan []byte // Images are exchanged a row at a time
+	input    chan<- []byte
 }
 
+// worldRule is the rule recorded in world files, as it is the only one the workers implement.
+const worldRule = "B3/S23"
+
 // ioState is the internal ioState of the io goroutine.
 type ioState struct {
 	params   Params
@@ -30,113 +36,241 @@ type ioCommand uint8
 // It will evaluate to:
 //		ioOutput 	= 0
 //		ioInput 	= 1
-//		ioCheckIdle = 2
 const (
 	ioOutput ioCommand = iota
 	ioInput
-	ioCheckIdle
 )
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
+// Returns the directory images are written to
+func outputDir(p Params) string {
+	if p.OutputDir == "" {
+		return "out"
+	}
+	return p.OutputDir
+}
 
-	filename := <-io.channels.filename
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
+// Returns the directory images are read from
+func inputDir(p Params) string {
+	if p.InputDir == "" {
+		return "images"
+	}
+	return p.InputDir
+}
+
+// Returns the file extension of images in the given format
+func imageExtension(format ImageFormat) string {
+	if format == WorldFormat {
+		return ".world"
+	}
+	return ".pgm"
+}
 
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
+// writePgmImage receives an image a row at a time and writes it to a pgm file, or a world file if the params ask
+// for them.
+// All of the rows are received before the file is created, so the distributor is never left blocked on a failure.
+func (io *ioState) writePgmImage(ctx context.Context) error {
+	var filename string
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case filename = <-io.channels.filename:
+	}
+	var turn int
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case turn = <-io.channels.turn:
+	}
 
 	world := make([][]byte, io.params.ImageHeight)
-	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
+	for y := range world {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case world[y] = <-io.channels.output:
+		}
 	}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			val := <-io.channels.output
-			//if val != 0 {
-			//	fmt.Println(x, y)
-			//}
-			world[y][x] = val
-		}
+	dir := outputDir(io.params)
+	_ = os.MkdirAll(dir, os.ModePerm)
+	file, ioError := os.Create(filepath.Join(dir, filename+imageExtension(io.params.ImageFormat)))
+	if ioError != nil {
+		return ioError
 	}
+	defer file.Close()
+	writer := bufio.NewWriter(file) // Any error writing is kept by the writer and returned by Flush
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
+	if io.params.ImageFormat == WorldFormat {
+		header := util.WorldHeader{
+			Width:  io.params.ImageWidth,
+			Height: io.params.ImageHeight,
+			Turn:   turn,
+			Rule:   worldRule,
+		}
+		ioError = util.WriteWorld(writer, header, world)
+		if ioError != nil {
+			return ioError
 		}
+		return io.finishWriting(file, writer, filename)
 	}
 
-	ioError = file.Sync()
-	util.Check(ioError)
+	_, _ = writer.WriteString("P5\n")
+	//_, _ = writer.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
+	_, _ = writer.WriteString(strconv.Itoa(io.params.ImageWidth))
+	_, _ = writer.WriteString(" ")
+	_, _ = writer.WriteString(strconv.Itoa(io.params.ImageHeight))
+	_, _ = writer.WriteString("\n")
+	_, _ = writer.WriteString(strconv.Itoa(255))
+	_, _ = writer.WriteString("\n")
 
-	fmt.Println("File", filename, "output done!")
+	for _, row := range world {
+		_, _ = writer.Write(row)
+	}
+
+	return io.finishWriting(file, writer, filename)
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
-	filename := <-io.channels.filename
-	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
+// finishWriting flushes an image that has been written to disk and records how large it is.
+func (io *ioState) finishWriting(file *os.File, writer *bufio.Writer, filename string) error {
+	ioError := writer.Flush()
+	if ioError != nil {
+		return ioError
+	}
+	ioError = file.Sync()
+	if ioError != nil {
+		return ioError
+	}
+	if info, err := file.Stat(); err == nil {
+		io.params.Metrics.addBytesWritten(info.Size())
+	}
 
-	fields := strings.Fields(string(data))
+	fmt.Println("File", filename, "output done!")
+	return nil
+}
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+// readPgmImage opens a pgm file and sends its data a row at a time as it is read.
+// If the file cannot be read nothing is sent and the error is returned instead.
+func (io *ioState) readPgmImage(ctx context.Context) error {
+	var filename string
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case filename = <-io.channels.filename:
 	}
-
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+	if io.params.InitialCells != nil {
+		return io.sendInitialCells(ctx)
 	}
-
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+	path := filepath.Join(inputDir(io.params), filename+imageExtension(io.params.ImageFormat))
+	if io.params.ImageFormat == WorldFormat {
+		return io.readWorldImage(ctx, path, filename)
+	}
+	file, ioError := os.Open(path)
+	if ioError != nil {
+		return ioError
+	}
+	defer file.Close()
+	reader, ioError := util.NewPGMReader(file)
+	if ioError != nil {
+		return fmt.Errorf("%v: %v", filename, ioError)
+	}
+	if reader.Width != io.params.ImageWidth || reader.Height != io.params.ImageHeight {
+		return fmt.Errorf("%v: incorrect size %vx%v", filename, reader.Width, reader.Height)
 	}
 
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+	for y := 0; y < reader.Height; y++ { // Each row is read into its own slice, which is not used again
+		row, ioError := reader.ReadRow()
+		if ioError != nil {
+			return fmt.Errorf("%v: %v", filename, ioError)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case io.channels.input <- row:
+		}
 	}
 
-	image := []byte(fields[4])
+	fmt.Println("File", filename, "input done!")
+	return nil
+}
+
+// readWorldImage reads a world file and sends it a row at a time, checking that it is the size the params expect and
+// uses the rule the workers implement.
+func (io *ioState) readWorldImage(ctx context.Context, path string, filename string) error {
+	file, ioError := os.Open(path)
+	if ioError != nil {
+		return ioError
+	}
+	defer file.Close()
+	header, world, ioError := util.ReadWorld(file)
+	if ioError != nil {
+		return fmt.Errorf("%v: %v", filename, ioError)
+	}
+	if header.Width != io.params.ImageWidth || header.Height != io.params.ImageHeight {
+		return fmt.Errorf("%v: incorrect size %vx%v", filename, header.Width, header.Height)
+	}
+	if !strings.EqualFold(header.Rule, worldRule) {
+		return fmt.Errorf("%v: unsupported rule %v", filename, header.Rule)
+	}
 
-	for _, b := range image {
-		io.channels.input <- b
+	for _, row := range world {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case io.channels.input <- row:
+		}
 	}
 
 	fmt.Println("File", filename, "input done!")
+	return nil
+}
+
+// sendInitialCells sends the world with the initial cells given in the params alive, in place of reading an image.
+func (io *ioState) sendInitialCells(ctx context.Context) error {
+	world := make([][]byte, io.params.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, io.params.ImageWidth)
+	}
+	for _, cell := range io.params.InitialCells {
+		if cell.X < 0 || cell.X >= io.params.ImageWidth || cell.Y < 0 || cell.Y >= io.params.ImageHeight {
+			return fmt.Errorf("initial cell (%v, %v) is outside the world", cell.X, cell.Y)
+		}
+		world[cell.Y][cell.X] = 255
+	}
+	for _, row := range world {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case io.channels.input <- row:
+		}
+	}
+	return nil
 }
 
 // startIo should be the entrypoint of the io goroutine.
-func startIo(p Params, c ioChannels) {
+// It runs until ctx is cancelled, reporting the result of every input and output on the result channel.
+func startIo(ctx context.Context, p Params, c ioChannels) {
 	io := ioState{
 		params:   p,
 		channels: c,
 	}
 
 	for {
+		var err error
 		select {
+		case <-ctx.Done():
+			return
 		case command := <-io.channels.command:
 			switch command {
 			case ioInput:
-				io.readPgmImage()
+				err = io.readPgmImage(ctx)
 			case ioOutput:
-				io.writePgmImage()
-			case ioCheckIdle:
-				io.channels.idle <- true
+				err = io.writePgmImage(ctx)
 			}
 		}
+		select {
+		case <-ctx.Done():
+			return
+		case io.channels.result <- err:
+		}
 	}
 }
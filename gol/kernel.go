@@ -0,0 +1,115 @@
+package gol
+
+import (
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Kernel is the way the dense backend works out the next state of each cell of its strips.
+type Kernel int
+
+const (
+	// LookupKernel slides a 3x3 window along each row as a 9 bit index into a table of next states.
+	LookupKernel Kernel = iota
+	// ColumnKernel sums each column of 3 cells once, then adds 3 neighbouring sums together for each cell.
+	ColumnKernel
+	// NaiveKernel gathers the 8 neighbours of each cell into a slice and counts them, which is much slower.
+	NaiveKernel
+)
+
+// nextStates holds the next state of the middle cell of every 3x3 window of cells, indexed by windowIndex.
+var nextStates = func() [512]byte {
+	var table [512]byte
+	for index := range table {
+		liveNeighbours := 0
+		for bit := uint(0); bit < 9; bit++ {
+			if bit != 4 && index&(1<<bit) != 0 { // Bit 4 is the middle cell
+				liveNeighbours++
+			}
+		}
+		middle := byte(0)
+		if index&(1<<4) != 0 {
+			middle = 255
+		}
+		table[index] = calcValue(middle, liveNeighbours)
+	}
+	return table
+}()
+
+// Returns a column of 3 cells as 3 bits, with the top cell in the lowest bit
+func columnBits(above []byte, row []byte, below []byte, x int) int {
+	return int(above[x]&1) | int(row[x]&1)<<1 | int(below[x]&1)<<2
+}
+
+// Returns the next state of a part of a world using a kernel, reporting the cells that change
+// Like calcNextState, the part has an extra row above and below that are only read
+func calcNextStateWith(kernel Kernel, reporter *flipReporter, world [][]byte, startY int) ([][]byte, error) {
+	nextWorld := make([][]byte, len(world)-2)
+	sums := make([]byte, len(world[0]))
+	for y := range nextWorld {
+		above, row, below := world[y], world[y+1], world[y+2]
+		nextRow := make([]byte, len(row))
+		calcNextRow(kernel, above, row, below, sums, nextRow)
+		nextWorld[y] = nextRow
+		for x, value := range nextRow {
+			if value != row[x] { // If the value of the cell has changed report it as flipped
+				err := reporter.flip(util.Cell{X: x, Y: y + startY})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return nextWorld, reporter.flush()
+}
+
+// Fills nextRow with the next state of row using a kernel, wrapping around the ends of the row
+// The column kernel keeps its sums in the given slice, which must be as long as the row
+func calcNextRow(kernel Kernel, above []byte, row []byte, below []byte, sums []byte, nextRow []byte) {
+	switch kernel {
+	case ColumnKernel:
+		nextRowFromSums(above, row, below, sums, nextRow)
+	case NaiveKernel:
+		rows := [][]byte{above, row, below}
+		for x, element := range row {
+			nextRow[x] = calcValue(element, calcLiveNeighbours(getNeighbours(rows, 1, x)))
+		}
+	default:
+		nextRowFromTable(above, row, below, nextRow)
+	}
+}
+
+// Fills nextRow with the next state of row, looking up each 3x3 window in nextStates
+// The index holds the columns to the left, middle and right in its high, middle and low 3 bits, so moving right is a
+// shift and a new column, and only the ends of the row wrap around
+func nextRowFromTable(above []byte, row []byte, below []byte, nextRow []byte) {
+	width := len(row)
+	index := columnBits(above, row, below, width-1)<<3 | columnBits(above, row, below, 0)
+	for x := 0; x < width-1; x++ {
+		index = (index<<3 | columnBits(above, row, below, x+1)) & 511
+		nextRow[x] = nextStates[index]
+	}
+	index = (index<<3 | columnBits(above, row, below, 0)) & 511
+	nextRow[width-1] = nextStates[index]
+}
+
+// Fills nextRow with the next state of row, summing each column once into sums and then 3 sums for each cell
+func nextRowFromSums(above []byte, row []byte, below []byte, sums []byte, nextRow []byte) {
+	width := len(row)
+	for x := range row {
+		sums[x] = above[x]&1 + row[x]&1 + below[x]&1
+	}
+	for x := range row {
+		left, right := x-1, x+1
+		if x == 0 {
+			left = width - 1
+		}
+		if right == width {
+			right = 0
+		}
+		liveNeighbours := sums[left] + sums[x] + sums[right] - row[x]&1
+		nextRow[x] = 0
+		if liveNeighbours == 3 || liveNeighbours == 2 && row[x] != 0 {
+			nextRow[x] = 255
+		}
+	}
+}
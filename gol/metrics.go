@@ -0,0 +1,135 @@
+package gol
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics collects measurements of a run while it happens, so that long runs can be monitored.
+// It is updated by the distributor, workers and io goroutine of the run it is given to in Params, and is safe to
+// sample from other goroutines at any time. A nil *Metrics collects nothing.
+type Metrics struct {
+	mutex          sync.Mutex
+	completedTurns int
+	aliveCells     int
+	workerTime     []time.Duration
+	bytesWritten   int64
+	events         chan<- Event
+	bus            *Bus // The bus the run's events are forwarded to, whose subscribers' backlogs are reported
+	turnsPerSecond float64
+	rateTurns      int       // The completed turns at the start of the window turnsPerSecond is measured over
+	rateStart      time.Time // The time the window started
+}
+
+// MetricsSample is the value of every metric at the time it was sampled.
+type MetricsSample struct {
+	CompletedTurns    int
+	TurnsPerSecond    float64         // Measured over roughly the last second, so it drops to 0 when a run stalls
+	AliveCells        int             // Updated every 2 seconds along with AliveCellsCount, and at the end of the run
+	WorkerComputeTime []time.Duration // The total time each worker has spent calculating its part of the world
+	EventBacklog      int             // The most events waiting for a subscriber of WatchBus, or in the events channel
+	BytesWritten      int64           // The number of bytes of images written by the io goroutine
+}
+
+// rateWindow is how long turns are counted for to measure the number of turns per second.
+const rateWindow = time.Second
+
+// NewMetrics returns metrics ready to be given to a run.
+func NewMetrics() *Metrics {
+	return &Metrics{rateStart: time.Now()}
+}
+
+// WatchBus reports the backlog of the bus a run's events are forwarded to, which is where events wait for a slow
+// consumer, rather than that of the events channel, which the bus drains straight away.
+func (m *Metrics) WatchBus(b *Bus) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bus = b
+}
+
+// Resets the metrics for a new run
+func (m *Metrics) start(events chan<- Event, threads int) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.completedTurns, m.aliveCells, m.bytesWritten = 0, 0, 0
+	m.workerTime = make([]time.Duration, threads)
+	m.events = events
+	m.turnsPerSecond, m.rateTurns, m.rateStart = 0, 0, time.Now()
+}
+
+// Records a completed turn, closing the window turns per second are measured over once it is long enough
+func (m *Metrics) turnCompleted(completedTurns int) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.completedTurns = completedTurns
+	if elapsed := time.Since(m.rateStart); elapsed >= rateWindow {
+		m.turnsPerSecond = float64(completedTurns-m.rateTurns) / elapsed.Seconds()
+		m.rateTurns = completedTurns
+		m.rateStart = m.rateStart.Add(elapsed)
+	}
+}
+
+// Records the number of alive cells
+func (m *Metrics) setAliveCells(aliveCells int) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.aliveCells = aliveCells
+}
+
+// Adds to the time a worker has spent calculating
+func (m *Metrics) addWorkerTime(worker int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if worker < len(m.workerTime) {
+		m.workerTime[worker] += duration
+	}
+}
+
+// Adds to the number of bytes written by the io goroutine
+func (m *Metrics) addBytesWritten(bytes int64) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bytesWritten += bytes
+}
+
+// Sample returns the current value of every metric.
+func (m *Metrics) Sample() MetricsSample {
+	if m == nil {
+		return MetricsSample{}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	sample := MetricsSample{
+		CompletedTurns:    m.completedTurns,
+		TurnsPerSecond:    m.turnsPerSecond,
+		AliveCells:        m.aliveCells,
+		WorkerComputeTime: append([]time.Duration(nil), m.workerTime...),
+		EventBacklog:      len(m.events),
+		BytesWritten:      m.bytesWritten,
+	}
+	if m.bus != nil {
+		sample.EventBacklog = m.bus.Backlog()
+	}
+	if elapsed := time.Since(m.rateStart); elapsed >= 2*rateWindow { // No turn has closed the window for a while
+		sample.TurnsPerSecond = float64(m.completedTurns-m.rateTurns) / elapsed.Seconds()
+	}
+	return sample
+}
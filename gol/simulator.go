@@ -0,0 +1,157 @@
+package gol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// unlimitedTurns is used for workers that keep processing turns until they are cancelled.
+const unlimitedTurns = int(^uint(0) >> 1)
+
+// Simulator evolves a world a number of turns at a time using the same parallel workers as Run.
+// Unlike Run there are no events, key presses or io goroutine, the caller drives each turn directly.
+// A Simulator is safe for use by multiple goroutines, and Close should be called once it is no longer needed.
+type Simulator struct {
+	mutex          sync.Mutex
+	world          [][]byte
+	completedTurns int
+	threads        int
+	parts          []chan [][]byte
+	startYValues   []int
+	sectionHeights []int
+	ctx            context.Context
+	cancel         context.CancelFunc
+	workers        sync.WaitGroup
+}
+
+// Snapshot is a copy of a simulator's world taken between turns.
+type Snapshot struct {
+	CompletedTurns int
+	World          [][]byte
+}
+
+// ErrClosed is returned when stepping a Simulator that has been closed.
+var ErrClosed = errors.New("gol: simulator is closed")
+
+// NewSimulator starts p.Threads workers for a p.ImageWidth x p.ImageHeight world with the given cells alive, or one
+// for each row if there are fewer rows than threads.
+// p.Turns is ignored as turns are performed by calling Step, and the world is always split into strips of rows, but
+// the rest of p is checked with Params.Validate.
+func NewSimulator(p Params, alive []util.Cell) (*Simulator, error) {
+	p.Turns = 0
+	err := p.Validate()
+	if err != nil {
+		return nil, err
+	}
+	world := make([][]byte, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, p.ImageWidth)
+	}
+	for _, cell := range alive {
+		if cell.X < 0 || cell.X >= p.ImageWidth || cell.Y < 0 || cell.Y >= p.ImageHeight {
+			return nil, fmt.Errorf("gol: alive cell (%v, %v) is outside the world", cell.X, cell.Y)
+		}
+		world[cell.Y][cell.X] = 255
+	}
+	threads := minInt(p.Threads, p.ImageHeight) // Every worker needs at least one row
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Simulator{
+		world:   world,
+		threads: threads,
+		parts:   createPartChannels(threads),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	s.sectionHeights = calcSectionHeights(p.ImageHeight, threads)
+	s.startYValues = calcStartYValues(s.sectionHeights)
+	for i, part := range s.parts {
+		s.workers.Add(1)
+		go func(part chan [][]byte, startY int) {
+			defer s.workers.Done()
+			worker(ctx, part, nil, FlipNone, startY, unlimitedTurns, p.Kernel, nil, 0)
+		}(part, s.startYValues[i])
+	}
+	return s, nil
+}
+
+// Step performs n turns of the world.
+func (s *Simulator) Step(n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := 0; i < n; i++ {
+		nextWorld, err := calcNextWorld(s.ctx, s.parts, s.startYValues, s.sectionHeights, s.world, s.threads)
+		if err != nil {
+			return ErrClosed
+		}
+		s.world = nextWorld
+		s.completedTurns++
+	}
+	return nil
+}
+
+// CompletedTurns returns the number of turns performed so far.
+func (s *Simulator) CompletedTurns() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.completedTurns
+}
+
+// Returns a copy of a world that can be changed without affecting the original
+func copyWorld(world [][]byte) [][]byte {
+	worldCopy := make([][]byte, len(world))
+	for y, row := range world {
+		worldCopy[y] = append([]byte(nil), row...)
+	}
+	return worldCopy
+}
+
+// World returns a copy of the current world, where alive cells are 255 and dead cells are 0.
+func (s *Simulator) World() [][]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return copyWorld(s.world)
+}
+
+// Snapshot returns a copy of the current world along with the number of turns it took to reach it.
+func (s *Simulator) Snapshot() Snapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return Snapshot{
+		CompletedTurns: s.completedTurns,
+		World:          copyWorld(s.world),
+	}
+}
+
+// SetCell sets the cell at the given coordinates to be alive or dead, wrapping coordinates outside the world.
+func (s *Simulator) SetCell(x, y int, alive bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	height, width := len(s.world), len(s.world[0])
+	x, y = ((x%width)+width)%width, ((y%height)+height)%height
+	value := byte(0)
+	if alive {
+		value = 255
+	}
+	s.world[y][x] = value
+}
+
+// AliveCount returns the number of alive cells in the current world.
+func (s *Simulator) AliveCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return calcNumAliveCells(s.world)
+}
+
+// AliveCells returns the alive cells in the snapshot's world.
+func (snapshot Snapshot) AliveCells() []util.Cell {
+	return getAliveCells(snapshot.World)
+}
+
+// Close stops the simulator's workers, after which Step returns ErrClosed.
+func (s *Simulator) Close() {
+	s.cancel()
+	s.workers.Wait()
+}
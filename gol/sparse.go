@@ -0,0 +1,364 @@
+package gol
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// sparseSettings are shared by every turn of a sparse board.
+type sparseSettings struct {
+	width, height int
+	infinite      bool // Cells live beyond the edges of the image instead of wrapping around them
+	threads       int
+	events        chan<- Event
+	flips         FlipMode
+	metrics       *Metrics
+}
+
+// sparseBoard stores only the alive cells of the world, as the columns of the alive cells in each row that has any.
+// Each turn only looks at the rows next to alive cells, so the time and memory it takes depend on the population
+// rather than the size of the world.
+// Rows are never changed once they are in the map, so boards share them and copying a board only copies the map.
+type sparseBoard struct {
+	cells      map[int][]int // The columns of the alive cells in each row, in order
+	population int
+	view       util.Cell // Where the top left cell of the image is, which only moves on an infinite plane
+	settings   *sparseSettings
+}
+
+// sparseRow is a row of the next turn of a sparse board, calculated by a worker.
+type sparseRow struct {
+	y       int
+	columns []int
+}
+
+// Returns an empty sparse board for a run, whose cells are set as it is loaded
+func newSparseBoard(p Params, events chan<- Event) *sparseBoard {
+	return &sparseBoard{
+		cells: make(map[int][]int),
+		settings: &sparseSettings{
+			width:    p.ImageWidth,
+			height:   p.ImageHeight,
+			infinite: p.InfinitePlane,
+			threads:  p.Threads,
+			events:   events,
+			flips:    p.FlipEvents,
+			metrics:  p.Metrics,
+		},
+	}
+}
+
+// Returns a column wrapped around the edges of the world, unless it is an infinite plane
+func (s *sparseSettings) wrapX(x int) int {
+	if s.infinite {
+		return x
+	}
+	return ((x % s.width) + s.width) % s.width
+}
+
+// Returns a row wrapped around the edges of the world, unless it is an infinite plane
+func (s *sparseSettings) wrapY(y int) int {
+	if s.infinite {
+		return y
+	}
+	return ((y % s.height) + s.height) % s.height
+}
+
+// Returns the cell in the coordinates of the image, and true if it is within the image so can be shown and saved
+func (b *sparseBoard) toImage(cell util.Cell) (util.Cell, bool) {
+	cell = util.Cell{X: cell.X - b.view.X, Y: cell.Y - b.view.Y}
+	return cell, cell.X >= 0 && cell.X < b.settings.width && cell.Y >= 0 && cell.Y < b.settings.height
+}
+
+// Returns true if a row of columns in order contains the given column
+func containsColumn(columns []int, x int) bool {
+	i := sort.SearchInts(columns, x)
+	return i < len(columns) && columns[i] == x
+}
+
+// Returns the rows that may have alive cells after the next turn, which are those next to a row with alive cells
+func (b *sparseBoard) candidateRows() []int {
+	seen := make(map[int]bool, len(b.cells)*3)
+	var ys []int
+	for y := range b.cells {
+		for dy := -1; dy <= 1; dy++ {
+			candidate := b.settings.wrapY(y + dy)
+			if !seen[candidate] {
+				seen[candidate] = true
+				ys = append(ys, candidate)
+			}
+		}
+	}
+	sort.Ints(ys)
+	return ys
+}
+
+// Returns the columns of the alive cells of a row after the next turn
+// The neighbours of every cell are gathered into the given buffer, which is returned so that it can be reused
+func (b *sparseBoard) nextRow(y int, neighbours []int) ([]int, []int) {
+	s := b.settings
+	neighbours = neighbours[:0]
+	for _, dy := range []int{-1, 1} {
+		for _, x := range b.cells[s.wrapY(y+dy)] {
+			neighbours = append(neighbours, s.wrapX(x-1), x, s.wrapX(x+1))
+		}
+	}
+	row := b.cells[y]
+	for _, x := range row {
+		neighbours = append(neighbours, s.wrapX(x-1), s.wrapX(x+1))
+	}
+	sort.Ints(neighbours) // Each column then appears once for every alive neighbour of the cell in it
+	var next []int
+	for i := 0; i < len(neighbours); {
+		x, j := neighbours[i], i
+		for j < len(neighbours) && neighbours[j] == x {
+			j++
+		}
+		liveNeighbours := j - i
+		if liveNeighbours == 3 || liveNeighbours == 2 && containsColumn(row, x) {
+			next = append(next, x)
+		}
+		i = j
+	}
+	return next, neighbours
+}
+
+// Reports the cells of a row that differ between two turns, in order
+func (b *sparseBoard) reportFlips(reporter *flipReporter, y int, before []int, after []int) error {
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		var x int
+		switch {
+		case j == len(after) || i < len(before) && before[i] < after[j]:
+			x = before[i]
+			i++
+		case i == len(before) || after[j] < before[i]:
+			x = after[j]
+			j++
+		default: // Alive in both turns
+			i++
+			j++
+			continue
+		}
+		if cell, ok := b.toImage(util.Cell{X: x, Y: y}); ok {
+			err := reporter.flip(cell)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Calculates the next turn of some of the rows of the board, reporting the cells that change
+func (b *sparseBoard) calcRows(ctx context.Context, ys []int, turn int) ([]sparseRow, error) {
+	reporter := newFlipReporter(ctx, b.settings.events, b.settings.flips, turn)
+	var rows []sparseRow
+	var next, neighbours []int
+	for _, y := range ys {
+		next, neighbours = b.nextRow(y, neighbours)
+		if len(next) > 0 {
+			rows = append(rows, sparseRow{y, next})
+		}
+		err := b.reportFlips(reporter, y, b.cells[y], next)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rows, reporter.flush()
+}
+
+// next splits the rows that may change between the workers, which all read the same board
+func (b *sparseBoard) next(ctx context.Context, turn int) (board, error) {
+	ys := b.candidateRows()
+	threads := b.settings.threads
+	if threads > len(ys) {
+		threads = len(ys)
+	}
+	results := make([][]sparseRow, threads)
+	errs := make([]error, threads)
+	workers := &sync.WaitGroup{}
+	for i := 0; i < threads; i++ {
+		workers.Add(1)
+		go func(i int) {
+			defer workers.Done()
+			started := time.Now()
+			results[i], errs[i] = b.calcRows(ctx, ys[i*len(ys)/threads:(i+1)*len(ys)/threads], turn)
+			b.settings.metrics.addWorkerTime(i, time.Since(started))
+		}(i)
+	}
+	workers.Wait()
+	nextBoard := &sparseBoard{cells: make(map[int][]int, len(b.cells)), view: b.view, settings: b.settings}
+	for i, rows := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		for _, row := range rows {
+			nextBoard.cells[row.y] = row.columns
+			nextBoard.population += len(row.columns)
+		}
+	}
+	return nextBoard, nil
+}
+
+func (b *sparseBoard) setRow(y int, row []byte) {
+	var columns []int
+	for x, cell := range row {
+		if cell == 255 {
+			columns = append(columns, x)
+		}
+	}
+	if len(columns) > 0 {
+		b.cells[y] = columns
+		b.population += len(columns)
+	}
+}
+
+// row builds the row of the image, which only holds the cells within it on an infinite plane
+func (b *sparseBoard) row(y int) []byte {
+	row := make([]byte, b.settings.width)
+	for _, x := range b.cells[y+b.view.Y] {
+		if x -= b.view.X; x >= 0 && x < len(row) {
+			row[x] = 255
+		}
+	}
+	return row
+}
+
+func (b *sparseBoard) rows() [][]byte {
+	rows := make([][]byte, b.settings.height)
+	for y := range rows {
+		rows[y] = b.row(y)
+	}
+	return rows
+}
+
+func (b *sparseBoard) height() int {
+	return b.settings.height
+}
+
+// set replaces the row of the cell rather than changing it, as the row may be shared with other boards
+func (b *sparseBoard) set(cell util.Cell, alive bool) (util.Cell, bool) {
+	cell.X, cell.Y = b.settings.wrapX(cell.X+b.view.X), b.settings.wrapY(cell.Y+b.view.Y)
+	columns := b.cells[cell.Y]
+	i := sort.SearchInts(columns, cell.X)
+	if (i < len(columns) && columns[i] == cell.X) == alive {
+		cell, _ = b.toImage(cell)
+		return cell, false
+	}
+	var replaced []int
+	if alive {
+		replaced = make([]int, 0, len(columns)+1)
+		replaced = append(append(append(replaced, columns[:i]...), cell.X), columns[i:]...)
+		b.population++
+	} else {
+		replaced = append(append(make([]int, 0, len(columns)-1), columns[:i]...), columns[i+1:]...)
+		b.population--
+	}
+	if len(replaced) == 0 {
+		delete(b.cells, cell.Y)
+	} else {
+		b.cells[cell.Y] = replaced
+	}
+	return b.toImage(cell)
+}
+
+func (b *sparseBoard) alive() []util.Cell {
+	ys := make([]int, 0, len(b.cells))
+	for y := range b.cells {
+		ys = append(ys, y)
+	}
+	sort.Ints(ys)
+	var cells []util.Cell
+	for _, y := range ys {
+		for _, x := range b.cells[y] {
+			cells = append(cells, util.Cell{X: x, Y: y})
+		}
+	}
+	return cells
+}
+
+func (b *sparseBoard) count() int {
+	return b.population
+}
+
+func (b *sparseBoard) copy() board {
+	cells := make(map[int][]int, len(b.cells))
+	for y, columns := range b.cells {
+		cells[y] = columns
+	}
+	return &sparseBoard{cells: cells, population: b.population, view: b.view, settings: b.settings}
+}
+
+// Returns the smallest rectangle holding every alive cell, as its top left and bottom right cells
+func (b *sparseBoard) bounds() (util.Cell, util.Cell) {
+	first := true
+	var min, max util.Cell
+	for y, columns := range b.cells {
+		left, right := columns[0], columns[len(columns)-1]
+		if first {
+			min, max = util.Cell{X: left, Y: y}, util.Cell{X: right, Y: y}
+			first = false
+			continue
+		}
+		if y < min.Y {
+			min.Y = y
+		} else if y > max.Y {
+			max.Y = y
+		}
+		if left < min.X {
+			min.X = left
+		}
+		if right > max.X {
+			max.X = right
+		}
+	}
+	return min, max
+}
+
+// Returns the distance the image is moved along an axis so that the population, from min to max, is in the middle
+// of it, or 0 if the population is far enough from the edges already
+func followAxis(view int, size int, min int, max int) int {
+	margin := size / 8
+	if margin < 1 {
+		margin = 1
+	}
+	target := (min+max+1)/2 - size/2
+	if min >= view+margin && max < view+size-margin {
+		return 0 // Well within the image
+	}
+	if max-min+1 > size-2*margin && target-view < margin && view-target < margin {
+		return 0 // Too big to fit, so only moved once its middle has drifted far enough
+	}
+	return target - view
+}
+
+// follow moves the image of an infinite plane once the population comes close to its edges
+func (b *sparseBoard) follow() bool {
+	if !b.settings.infinite || b.population == 0 {
+		return false
+	}
+	min, max := b.bounds()
+	dx := followAxis(b.view.X, b.settings.width, min.X, max.X)
+	dy := followAxis(b.view.Y, b.settings.height, min.Y, max.Y)
+	b.view.X += dx
+	b.view.Y += dy
+	return dx != 0 || dy != 0
+}
+
+func (b *sparseBoard) origin() util.Cell {
+	return b.view
+}
+
+func (b *sparseBoard) imageCells() []util.Cell {
+	var cells []util.Cell
+	for _, cell := range b.alive() {
+		if cell, ok := b.toImage(cell); ok {
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
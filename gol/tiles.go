@@ -0,0 +1,143 @@
+package gol
+
+import (
+	"context"
+	"time"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// tile is a rectangle of a world, from its top left cell up to but not including its bottom right cell.
+type tile struct {
+	x0, y0, x1, y1 int
+}
+
+// tileJob asks a tile worker to calculate the next state of a tile of a world into the rows of the next world.
+type tileJob struct {
+	tile      tile
+	world     [][]byte
+	nextWorld [][]byte // Each worker only writes to the cells of its own tile
+	turn      int
+	done      chan<- error
+}
+
+// tiles splits a dense world into squares that are handed to the workers one at a time, so that each worker only
+// works on a small part of the world at once and any number of workers can share any size of world.
+type tiles struct {
+	size    int
+	threads int
+	jobs    chan tileJob
+}
+
+// Returns the tiles of the given size, ready for workers to be started on them
+func newTiles(size int, threads int) *tiles {
+	return &tiles{size: size, threads: threads, jobs: make(chan tileJob)}
+}
+
+// Returns the tiles covering a world of the given size in rows of tiles, where those at the right and bottom edges
+// are cut short
+func (t *tiles) split(width int, height int) []tile {
+	var split []tile
+	for y0 := 0; y0 < height; y0 += t.size {
+		for x0 := 0; x0 < width; x0 += t.size {
+			split = append(split, tile{x0, y0, minInt(x0+t.size, width), minInt(y0+t.size, height)})
+		}
+	}
+	return split
+}
+
+// Returns the smaller of two ints
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Copies a tile into halo along with a cell on every side of it, including the corners, wrapping around the edges of
+// the world, and returns the rows of the copy
+func copyWithHalo(world [][]byte, t tile, halo [][]byte) [][]byte {
+	height, width := len(world), len(world[0])
+	halo = halo[:t.y1-t.y0+2]
+	for i := range halo {
+		row := world[((t.y0-1+i)%height+height)%height]
+		halo[i] = halo[i][:t.x1-t.x0+2]
+		halo[i][0] = row[((t.x0-1)%width+width)%width]
+		copy(halo[i][1:], row[t.x0:t.x1])
+		halo[i][len(halo[i])-1] = row[t.x1%width]
+	}
+	return halo
+}
+
+// Takes tiles from the jobs channel until it is cancelled, calculating the next state of each with the kernel
+// The time spent calculating is added to the metrics of the worker with the given id
+func tileWorker(ctx context.Context, jobs <-chan tileJob, size int, events chan<- Event, flips FlipMode,
+	kernel Kernel, metrics *Metrics, id int) {
+	halo := make([][]byte, size+2) // Reused for every tile, as are the sums and next row
+	for i := range halo {
+		halo[i] = make([]byte, size+2)
+	}
+	sums := make([]byte, size+2)
+	nextRow := make([]byte, size+2)
+	for {
+		var job tileJob
+		select {
+		case <-ctx.Done():
+			return
+		case job = <-jobs:
+		}
+		started := time.Now()
+		reporter := newFlipReporter(ctx, events, flips, job.turn)
+		t := job.tile
+		rows := copyWithHalo(job.world, t, halo)
+		width := t.x1 - t.x0 + 2
+		var err error
+		for y := 1; y < len(rows)-1 && err == nil; y++ {
+			calcNextRow(kernel, rows[y-1], rows[y], rows[y+1], sums[:width], nextRow[:width])
+			copy(job.nextWorld[t.y0+y-1][t.x0:t.x1], nextRow[1:width-1]) // The halo wraps wrongly, so is dropped
+			for x := 1; x < width-1; x++ {
+				if nextRow[x] != rows[y][x] {
+					err = reporter.flip(util.Cell{X: t.x0 + x - 1, Y: t.y0 + y - 1})
+					if err != nil {
+						break
+					}
+				}
+			}
+		}
+		if err == nil {
+			err = reporter.flush()
+		}
+		metrics.addWorkerTime(id, time.Since(started))
+		job.done <- err // Buffered for every tile, so never blocks
+	}
+}
+
+// Returns the next state of a world, handing its tiles out to the workers as they become free
+func (t *tiles) calcNextWorld(ctx context.Context, world [][]byte, turn int) ([][]byte, error) {
+	height, width := len(world), len(world[0])
+	nextWorld := make([][]byte, height)
+	for y := range nextWorld {
+		nextWorld[y] = make([]byte, width)
+	}
+	split := t.split(width, height)
+	done := make(chan error, len(split))
+	go func() {
+		for _, each := range split {
+			select {
+			case <-ctx.Done():
+				return
+			case t.jobs <- tileJob{tile: each, world: world, nextWorld: nextWorld, turn: turn, done: done}:
+			}
+		}
+	}()
+	for range split {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case err := <-done:
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nextWorld, nil
+}
@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Client uses the job API served by a Service. Errors the service returns are returned as the same values, so
+// they can be compared with ErrNotFound and the other errors.
+type Client struct {
+	URL  string       // The address the service is served at, such as http://localhost:8081
+	HTTP *http.Client // The client requests are made with, http.DefaultClient if nil
+}
+
+// NewClient returns a client for the service served at url.
+func NewClient(url string) *Client {
+	return &Client{URL: strings.TrimSuffix(url, "/")}
+}
+
+// serviceErrors are the errors a client recognises in responses, so it can return the same values.
+var serviceErrors = []error{ErrNotFound, ErrNotActive, ErrNotStarted, ErrNoResult, ErrClosed}
+
+// Makes a request to the service, decoding a JSON response into result unless it is nil, and returning the body
+func (c *Client) do(method string, path string, body interface{}, result interface{}) ([]byte, error) {
+	var requestBody bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&requestBody).Encode(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	request, err := http.NewRequest(method, c.URL+path, &requestBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		var failure errorResponse
+		if json.Unmarshal(data, &failure) != nil || failure.Error == "" {
+			return nil, fmt.Errorf("jobs: %v %v: %v", method, path, response.Status)
+		}
+		for _, err := range serviceErrors {
+			if err.Error() == failure.Error {
+				return nil, err
+			}
+		}
+		return nil, errors.New(failure.Error)
+	}
+	if result != nil {
+		err = json.Unmarshal(data, result)
+	}
+	return data, err
+}
+
+// Submit queues a job, returning its status.
+func (c *Client) Submit(spec Spec) (Status, error) {
+	var status Status
+	_, err := c.do(http.MethodPost, "/jobs", spec, &status)
+	return status, err
+}
+
+// List returns the status of every job in the order they were submitted.
+func (c *Client) List() ([]Status, error) {
+	var statuses []Status
+	_, err := c.do(http.MethodGet, "/jobs", nil, &statuses)
+	return statuses, err
+}
+
+// Status returns the status of a job.
+func (c *Client) Status(id int) (Status, error) {
+	var status Status
+	_, err := c.do(http.MethodGet, fmt.Sprintf("/jobs/%v", id), nil, &status)
+	return status, err
+}
+
+// Pause pauses a running job.
+func (c *Client) Pause(id int) (Status, error) {
+	var status Status
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/jobs/%v/pause", id), nil, &status)
+	return status, err
+}
+
+// Resume resumes a paused job.
+func (c *Client) Resume(id int) (Status, error) {
+	var status Status
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/jobs/%v/resume", id), nil, &status)
+	return status, err
+}
+
+// Cancel stops a queued or running job.
+func (c *Client) Cancel(id int) (Status, error) {
+	var status Status
+	_, err := c.do(http.MethodPost, fmt.Sprintf("/jobs/%v/cancel", id), nil, &status)
+	return status, err
+}
+
+// Wait polls the status of a job every interval until it has stopped, or ctx is done.
+func (c *Client) Wait(ctx context.Context, id int, interval time.Duration) (Status, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := c.Status(id)
+		if err != nil {
+			return status, err
+		}
+		switch status.State {
+		case Finished, Cancelled, Failed:
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// FinalImage downloads the PGM image written at the end of a finished job.
+func (c *Client) FinalImage(id int) ([]byte, error) {
+	return c.do(http.MethodGet, fmt.Sprintf("/jobs/%v/final.pgm", id), nil, nil)
+}
+
+// FinalPattern downloads the world at the end of a finished job as an RLE pattern.
+func (c *Client) FinalPattern(id int) (util.Pattern, error) {
+	data, err := c.do(http.MethodGet, fmt.Sprintf("/jobs/%v/final.rle", id), nil, nil)
+	if err != nil {
+		return util.Pattern{}, err
+	}
+	return util.ParseRLE(bytes.NewReader(data))
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// errorResponse is the JSON returned when a request fails.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP serves the job API:
+//
+//	GET  /jobs                  lists every job
+//	POST /jobs                  submits the Spec in the body, replying with the new job's Status
+//	GET  /jobs/{id}             returns a job's Status
+//	POST /jobs/{id}/pause       pauses a running job
+//	POST /jobs/{id}/resume      resumes a paused job
+//	POST /jobs/{id}/cancel      cancels a queued or running job
+//	GET  /jobs/{id}/final.pgm   downloads the final image of a finished job
+//	GET  /jobs/{id}/final.rle   downloads the final world of a finished job as an RLE pattern
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(path) == 0 || path[0] != "jobs" || len(path) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(path) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.List())
+		case http.MethodPost:
+			s.serveSubmit(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+	id, err := strconv.Atoi(path[1])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	action := ""
+	if len(path) == 3 {
+		action = path[2]
+	}
+	method := http.MethodPost
+	switch action {
+	case "", "final.pgm", "final.rle":
+		method = http.MethodGet
+	}
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var status Status
+	switch action {
+	case "":
+		status, err = s.Status(id)
+	case "pause":
+		status, err = s.Pause(id)
+	case "resume":
+		status, err = s.Resume(id)
+	case "cancel":
+		status, err = s.Cancel(id)
+	case "final.pgm":
+		s.serveImage(w, r, id)
+		return
+	case "final.rle":
+		s.servePattern(w, id)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// Submits the job described by the request body
+func (s *Service) serveSubmit(w http.ResponseWriter, r *http.Request) {
+	var spec Spec
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.config.MaxRequestBytes)).Decode(&spec)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status, err := s.Submit(spec)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, status)
+}
+
+// Serves the final image of a finished job
+func (s *Service) serveImage(w http.ResponseWriter, r *http.Request, id int) {
+	path, err := s.ImagePath(id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/x-portable-graymap")
+	http.ServeFile(w, r, path)
+}
+
+// Serves the final world of a finished job as an RLE pattern
+func (s *Service) servePattern(w http.ResponseWriter, id int) {
+	pattern, err := s.FinalPattern(id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = util.WriteRLE(w, pattern)
+}
+
+// Writes a value as the JSON body of a response
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// Writes an error message as the JSON body of a response
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, errorResponse{message})
+}
+
+// Writes an error returned by the service with the status code that matches it
+func writeServiceError(w http.ResponseWriter, err error) {
+	code := http.StatusBadRequest // Anything else is a spec that cannot be run
+	switch err {
+	case ErrNotFound:
+		code = http.StatusNotFound
+	case ErrNotActive, ErrNotStarted, ErrNoResult:
+		code = http.StatusConflict
+	case ErrClosed:
+		code = http.StatusServiceUnavailable
+	}
+	writeError(w, code, err.Error())
+}
@@ -0,0 +1,458 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// State is the stage a job has reached.
+type State string
+
+const (
+	// Queued jobs are waiting for enough threads to be free to start.
+	Queued State = "queued"
+	// Running jobs are being simulated.
+	Running State = "running"
+	// Paused jobs have been started but are not performing turns, they keep their threads while paused.
+	Paused State = "paused"
+	// Finished jobs have performed every turn and have a final image to download.
+	Finished State = "finished"
+	// Cancelled jobs were stopped by a client before they finished.
+	Cancelled State = "cancelled"
+	// Failed jobs stopped because of an error.
+	Failed State = "failed"
+)
+
+// StandardRule is the only rule jobs can be run with, as it is the one the workers implement.
+const StandardRule = "B3/S23"
+
+// Spec describes a job to run. The world is Width x Height and starts with the RLE Pattern placed with its top left
+// corner at X, Y, or the image in images of the same size if there is no pattern.
+type Spec struct {
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	Turns          int    `json:"turns"`
+	Threads        int    `json:"threads"`        // Defaults to 1, only one for each row is used
+	TurnsPerSecond int    `json:"turnsPerSecond"` // Defaults to 0, which is unlimited
+	Rule           string `json:"rule"`           // Defaults to StandardRule, which is the only one supported
+	Pattern        string `json:"pattern"`
+	X              int    `json:"x"`
+	Y              int    `json:"y"`
+}
+
+// Status is the state of a job, as reported to clients.
+type Status struct {
+	ID             int       `json:"id"`
+	State          State     `json:"state"`
+	Spec           Spec      `json:"spec"`
+	CompletedTurns int       `json:"completedTurns"`
+	AliveCells     int       `json:"aliveCells"` // Updated every 2 seconds while running, and when the job finishes
+	Submitted      time.Time `json:"submitted"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Config configures a Service.
+type Config struct {
+	Threads         int    // The number of threads shared by every running job
+	Dir             string // The directory each job's images are written to a subdirectory of, out/jobs by default
+	MaxArea         int    // The most cells a job's world can have, DefaultMaxArea by default
+	MaxRequestBytes int64  // The largest request body the API accepts, DefaultMaxRequestBytes by default
+}
+
+const (
+	// DefaultMaxArea lets a job have a world of up to 8192x8192 cells.
+	DefaultMaxArea = 8192 * 8192
+	// DefaultMaxRequestBytes lets a submitted spec, including its pattern, be up to 1MiB.
+	DefaultMaxRequestBytes = 1 << 20
+)
+
+var (
+	// ErrNotFound is returned for a job that does not exist.
+	ErrNotFound = errors.New("jobs: no such job")
+	// ErrNotActive is returned when pausing, resuming or cancelling a job that has already stopped.
+	ErrNotActive = errors.New("jobs: job has already stopped")
+	// ErrNotStarted is returned when pausing or resuming a job that is still queued.
+	ErrNotStarted = errors.New("jobs: job has not started")
+	// ErrNoResult is returned when downloading the result of a job that has not finished.
+	ErrNoResult = errors.New("jobs: job has not finished")
+	// ErrClosed is returned when submitting a job to a service that has been closed.
+	ErrClosed = errors.New("jobs: service is closed")
+)
+
+// job is a single submitted job and everything the service knows about its run.
+type job struct {
+	status   Status
+	params   gol.Params
+	commands chan gol.Command
+	cancel   context.CancelFunc
+	done     chan bool // Closed once the run has stopped
+	imageDir string
+	image    string      // The file name of the final image, once it has been written
+	final    []util.Cell // The alive cells after the final turn
+}
+
+// Service queues jobs and runs as many of them at once as the thread budget allows, in the order they were
+// submitted. It is safe for use by multiple goroutines, and Close should be called once it is no longer needed.
+type Service struct {
+	config  Config
+	ctx     context.Context
+	cancel  context.CancelFunc
+	runs    sync.WaitGroup
+	mutex   sync.Mutex
+	jobs    map[int]*job
+	queue   []*job
+	nextID  int
+	threads int // The number of threads used by running jobs
+	closed  bool
+}
+
+// NewService returns a service that runs jobs within the given thread budget.
+func NewService(config Config) (*Service, error) {
+	if config.Threads <= 0 {
+		return nil, errors.New("jobs: at least one thread is needed")
+	}
+	if config.Dir == "" {
+		config.Dir = filepath.Join("out", "jobs")
+	}
+	if config.MaxArea <= 0 {
+		config.MaxArea = DefaultMaxArea
+	}
+	if config.MaxRequestBytes <= 0 {
+		config.MaxRequestBytes = DefaultMaxRequestBytes
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[int]*job),
+		nextID: 1,
+	}, nil
+}
+
+// Returns the params to run a spec with, after filling in its defaults and checking it can be run in the budget
+func (s *Service) params(spec *Spec) (gol.Params, error) {
+	if spec.Threads == 0 {
+		spec.Threads = 1
+	}
+	if spec.Rule == "" {
+		spec.Rule = StandardRule
+	}
+	if !strings.EqualFold(spec.Rule, StandardRule) {
+		return gol.Params{}, fmt.Errorf("jobs: unsupported rule %q, only %v is supported", spec.Rule, StandardRule)
+	}
+	params := gol.Params{
+		Turns:          spec.Turns,
+		Threads:        spec.Threads,
+		ImageWidth:     spec.Width,
+		ImageHeight:    spec.Height,
+		TurnsPerSecond: spec.TurnsPerSecond,
+		FlipEvents:     gol.FlipNone,
+	}
+	err := params.Validate()
+	if err != nil {
+		return gol.Params{}, err
+	}
+	if params.ImageWidth > s.config.MaxArea/params.ImageHeight {
+		return gol.Params{}, fmt.Errorf("jobs: the world cannot have more than %v cells", s.config.MaxArea)
+	}
+	if params.Workers() > s.config.Threads {
+		return gol.Params{}, fmt.Errorf("jobs: the job needs %v threads, more than the %v there are",
+			params.Workers(), s.config.Threads)
+	}
+	if spec.Pattern == "" {
+		return params, nil
+	}
+	pattern, err := util.ParseRLE(strings.NewReader(spec.Pattern))
+	if err != nil {
+		return gol.Params{}, err
+	}
+	params.InitialCells = make([]util.Cell, 0, len(pattern.Cells))
+	for _, cell := range pattern.Cells {
+		x, y := cell.X+spec.X, cell.Y+spec.Y
+		if x < 0 || x >= spec.Width || y < 0 || y >= spec.Height {
+			return gol.Params{}, errors.New("jobs: the pattern does not fit in the world")
+		}
+		params.InitialCells = append(params.InitialCells, util.Cell{X: x, Y: y})
+	}
+	return params, nil
+}
+
+// Submit queues a job, returning its status or an error if the spec cannot be run.
+func (s *Service) Submit(spec Spec) (Status, error) {
+	params, err := s.params(&spec)
+	if err != nil {
+		return Status{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return Status{}, ErrClosed
+	}
+	j := &job{
+		status:   Status{ID: s.nextID, State: Queued, Spec: spec, Submitted: time.Now()},
+		params:   params,
+		done:     make(chan bool),
+		imageDir: filepath.Join(s.config.Dir, strconv.Itoa(s.nextID)),
+	}
+	j.params.OutputDir = j.imageDir
+	s.nextID++
+	s.jobs[j.status.ID] = j
+	s.queue = append(s.queue, j)
+	s.schedule()
+	return j.status, nil
+}
+
+// Starts queued jobs in the order they were submitted while there are enough threads for the next one
+// The mutex must be held
+func (s *Service) schedule() {
+	for len(s.queue) > 0 && s.threads+s.queue[0].params.Workers() <= s.config.Threads {
+		j := s.queue[0]
+		s.queue = s.queue[1:]
+		s.start(j)
+	}
+}
+
+// Starts running a job in the background
+// The mutex must be held
+func (s *Service) start(j *job) {
+	s.threads += j.params.Workers()
+	j.status.State = Running
+	ctx, cancel := context.WithCancel(s.ctx)
+	j.cancel = cancel
+	j.commands = make(chan gol.Command)
+	events := make(chan gol.Event, 100)
+	result := make(chan error, 1)
+	s.runs.Add(1)
+	go func() {
+		result <- gol.RunCommands(ctx, j.params, events, j.commands)
+	}()
+	go func() {
+		defer s.runs.Done()
+		for event := range events {
+			s.record(j, event)
+		}
+		err := <-result
+		cancel()
+		s.finish(j, err)
+	}()
+}
+
+// Updates the status of a job from one of its events
+func (s *Service) record(j *job, event gol.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch e := event.(type) {
+	case gol.TurnComplete:
+		j.status.CompletedTurns = e.CompletedTurns
+	case gol.AliveCellsCount:
+		j.status.AliveCells = e.CellsCount
+	case gol.FinalTurnComplete:
+		j.status.CompletedTurns = e.CompletedTurns
+		j.status.AliveCells = len(e.Alive)
+		j.final = e.Alive
+	case gol.ImageOutputComplete:
+		j.image = e.Filename
+	}
+}
+
+// Records how a job's run ended and frees its threads for the jobs still queued
+func (s *Service) finish(j *job, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.threads -= j.params.Workers()
+	switch {
+	case j.status.State == Cancelled:
+	case err != nil:
+		j.status.State = Failed
+		j.status.Error = err.Error()
+	default:
+		j.status.State = Finished
+	}
+	close(j.done)
+	s.schedule()
+}
+
+// List returns the status of every job in the order they were submitted.
+func (s *Service) List() []Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	sort.Slice(statuses, func(i, k int) bool {
+		return statuses[i].ID < statuses[k].ID
+	})
+	return statuses
+}
+
+// Status returns the status of a job.
+func (s *Service) Status(id int) (Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	return j.status, nil
+}
+
+// Sends a command to a running job and waits for it to be carried out, returning the job's status afterwards
+// Jobs are only controlled through the service, so the state the command leaves the job in is recorded here
+func (s *Service) control(id int, commandType gol.CommandType, newState State) (Status, error) {
+	s.mutex.Lock()
+	j, ok := s.jobs[id]
+	var state State
+	if ok {
+		state = j.status.State
+	}
+	s.mutex.Unlock()
+	switch {
+	case !ok:
+		return Status{}, ErrNotFound
+	case state == Queued:
+		return Status{}, ErrNotStarted
+	case state != Running && state != Paused:
+		return Status{}, ErrNotActive
+	}
+	replies := make(chan gol.Reply, 1)
+	select {
+	case j.commands <- gol.Command{Type: commandType, Reply: replies}:
+	case <-j.done:
+		return Status{}, ErrNotActive
+	}
+	var reply gol.Reply
+	select {
+	case reply = <-replies:
+	case <-j.done:
+		return Status{}, ErrNotActive
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if j.status.State == Running || j.status.State == Paused { // Unless it was cancelled in the meantime
+		j.status.State = newState
+	}
+	if reply.CompletedTurns > j.status.CompletedTurns { // The events for the last turns may not be recorded yet
+		j.status.CompletedTurns = reply.CompletedTurns
+	}
+	return j.status, nil
+}
+
+// Pause pauses a running job, which keeps its threads until it is resumed or cancelled.
+func (s *Service) Pause(id int) (Status, error) {
+	return s.control(id, gol.PauseCommand, Paused)
+}
+
+// Resume resumes a paused job.
+func (s *Service) Resume(id int) (Status, error) {
+	return s.control(id, gol.ResumeCommand, Running)
+}
+
+// Cancel stops a job, removing it from the queue if it has not started yet. No final image is written for it.
+func (s *Service) Cancel(id int) (Status, error) {
+	s.mutex.Lock()
+	j, ok := s.jobs[id]
+	if !ok {
+		s.mutex.Unlock()
+		return Status{}, ErrNotFound
+	}
+	switch j.status.State {
+	case Queued:
+		for i, queued := range s.queue {
+			if queued == j {
+				s.queue = append(s.queue[:i], s.queue[i+1:]...)
+				break
+			}
+		}
+		j.status.State = Cancelled
+		close(j.done)
+		s.schedule() // Jobs behind it may now fit
+	case Running, Paused:
+		j.status.State = Cancelled
+		j.cancel()
+	default:
+		s.mutex.Unlock()
+		return Status{}, ErrNotActive
+	}
+	s.mutex.Unlock()
+	<-j.done
+	return s.Status(id)
+}
+
+// Wait blocks until a job has stopped, or ctx is done, and returns its status.
+func (s *Service) Wait(ctx context.Context, id int) (Status, error) {
+	s.mutex.Lock()
+	j, ok := s.jobs[id]
+	s.mutex.Unlock()
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	select {
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	case <-j.done:
+	}
+	return s.Status(id)
+}
+
+// ImagePath returns the path of the PGM image written at the end of a finished job.
+func (s *Service) ImagePath(id int) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if j.status.State != Finished || j.image == "" {
+		return "", ErrNoResult
+	}
+	return filepath.Join(j.imageDir, j.image+".pgm"), nil
+}
+
+// FinalPattern returns the alive cells at the end of a finished job, as a pattern the size of its world.
+func (s *Service) FinalPattern(id int) (util.Pattern, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return util.Pattern{}, ErrNotFound
+	}
+	if j.status.State != Finished {
+		return util.Pattern{}, ErrNoResult
+	}
+	return util.Pattern{
+		Name:   fmt.Sprintf("Job %v after %v turns", j.status.ID, j.status.CompletedTurns),
+		Width:  j.params.ImageWidth,
+		Height: j.params.ImageHeight,
+		Cells:  j.final,
+	}, nil
+}
+
+// Close cancels every job that has not stopped and waits for their runs to finish.
+func (s *Service) Close() {
+	s.mutex.Lock()
+	s.closed = true
+	for _, j := range s.queue {
+		j.status.State = Cancelled
+		close(j.done)
+	}
+	s.queue = nil
+	for _, j := range s.jobs {
+		if j.status.State == Running || j.status.State == Paused {
+			j.status.State = Cancelled
+		}
+	}
+	s.mutex.Unlock()
+	s.cancel()
+	s.runs.Wait()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/jobs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Waits for a job to stop, failing the test if it takes longer than ten seconds
+func waitForJob(t *testing.T, client *jobs.Client, id int) jobs.Status {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	status, err := client.Wait(ctx, id, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+// TestJobs submits jobs through an in-process client and checks their results, how they share the thread budget
+// and that they can be paused, resumed and cancelled.
+func TestJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	service, err := jobs.NewService(jobs.Config{Threads: 4, Dir: dir, MaxArea: 1 << 20, MaxRequestBytes: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+	httpServer := httptest.NewServer(service)
+	defer httpServer.Close()
+	client := jobs.NewClient(httpServer.URL)
+
+	t.Run("image", func(t *testing.T) {
+		status, err := client.Submit(jobs.Spec{Width: 16, Height: 16, Turns: 100, Threads: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		status = waitForJob(t, client, status.ID)
+		if status.State != jobs.Finished || status.CompletedTurns != 100 {
+			t.Fatalf("expected the job to finish after 100 turns, got %+v", status)
+		}
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 4}
+		expected := util.ReadAliveCells("check/images/16x16x100.pgm", 16, 16)
+		pattern, err := client.FinalPattern(status.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqualBoard(t, pattern.Cells, expected, p)
+		image, err := client.FinalImage(status.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedImage, err := ioutil.ReadFile("check/images/16x16x100.pgm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(image) != string(expectedImage) {
+			t.Error("expected the final image to match check/images/16x16x100.pgm")
+		}
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		glider := "x = 3, y = 3\nbo$2bo$3o!"
+		status, err := client.Submit(jobs.Spec{Width: 8, Height: 8, Turns: 4, Threads: 2, Pattern: glider, X: 2, Y: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		status = waitForJob(t, client, status.ID)
+		if status.State != jobs.Finished || status.AliveCells != 5 {
+			t.Fatalf("expected the job to finish with 5 alive cells, got %+v", status)
+		}
+		pattern, err := client.FinalPattern(status.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moved := []util.Cell{{X: 4, Y: 2}, {X: 5, Y: 3}, {X: 3, Y: 4}, {X: 4, Y: 4}, {X: 5, Y: 4}}
+		assertEqualBoard(t, pattern.Cells, moved, gol.Params{ImageWidth: 8, ImageHeight: 8})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, spec := range []jobs.Spec{
+			{Width: 0, Height: 16},
+			{Width: 16, Height: 16, Threads: 5},
+			{Width: 16, Height: 16, Rule: "B36/S23"},
+			{Width: 16, Height: 16, Pattern: "x = 3, y = 3\n3o!", X: 14},
+			{Width: 2048, Height: 1024},
+			{Width: 16, Height: 16, Pattern: "x = 1, y = 1\n1000000000o!"},
+			{Width: 16, Height: 16, Pattern: "#C " + strings.Repeat("-", 4096) + "\nx = 1, y = 1\no!"},
+		} {
+			_, err := client.Submit(spec)
+			if err == nil {
+				t.Errorf("expected %+v to be rejected", spec)
+			}
+		}
+		_, err := client.Status(1000)
+		if err != jobs.ErrNotFound {
+			t.Errorf("expected %v, got %v", jobs.ErrNotFound, err)
+		}
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		long := jobs.Spec{Width: 64, Height: 64, Turns: 100000000, Threads: 3}
+		first, err := client.Submit(long)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := client.Submit(jobs.Spec{Width: 64, Height: 64, Turns: 100000000, Threads: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.State != jobs.Running || second.State != jobs.Queued {
+			t.Fatalf("expected only the first job to fit in the budget, got %v and %v", first.State, second.State)
+		}
+		if _, err := client.Pause(second.ID); err != jobs.ErrNotStarted {
+			t.Errorf("expected pausing a queued job to return %v, got %v", jobs.ErrNotStarted, err)
+		}
+
+		status, err := client.Pause(first.ID)
+		if err != nil || status.State != jobs.Paused {
+			t.Fatalf("expected the job to be paused, got %+v, %v", status, err)
+		}
+		paused := status.CompletedTurns
+		time.Sleep(100 * time.Millisecond)
+		status, err = client.Status(first.ID)
+		if err != nil || status.CompletedTurns != paused {
+			t.Errorf("expected the paused job to stay at turn %v, got %+v, %v", paused, status, err)
+		}
+		status, err = client.Resume(first.ID)
+		if err != nil || status.State != jobs.Running {
+			t.Fatalf("expected the job to be running, got %+v, %v", status, err)
+		}
+
+		status, err = client.Cancel(first.ID)
+		if err != nil || status.State != jobs.Cancelled {
+			t.Fatalf("expected the job to be cancelled, got %+v, %v", status, err)
+		}
+		status, err = client.Status(second.ID)
+		if err != nil || status.State != jobs.Running {
+			t.Errorf("expected the queued job to start once threads were freed, got %+v, %v", status, err)
+		}
+		if _, err := client.FinalImage(first.ID); err != jobs.ErrNoResult {
+			t.Errorf("expected a cancelled job to have no result, got %v", err)
+		}
+		status, err = client.Cancel(second.ID)
+		if err != nil || status.State != jobs.Cancelled {
+			t.Fatalf("expected the job to be cancelled, got %+v, %v", status, err)
+		}
+		if _, err := client.Resume(second.ID); err != jobs.ErrNotActive {
+			t.Errorf("expected resuming a cancelled job to return %v, got %v", jobs.ErrNotActive, err)
+		}
+	})
+
+	t.Run("fewer rows than threads", func(t *testing.T) {
+		wide, err := client.Submit(jobs.Spec{Width: 64, Height: 64, Turns: 100000000, Threads: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		thin, err := client.Submit(jobs.Spec{Width: 64, Height: 1, Turns: 100000000, Threads: 64,
+			Pattern: "x = 3, y = 1\n3o!"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wide.State != jobs.Running || thin.State != jobs.Running {
+			t.Errorf("expected a job with one row to use only one thread, got %v and %v", wide.State, thin.State)
+		}
+		for _, id := range []int{wide.ID, thin.ID} {
+			if _, err := client.Cancel(id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	statuses, err := client.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, status := range statuses {
+		ids = append(ids, fmt.Sprint(status.ID))
+	}
+	if strings.Join(ids, ",") != "1,2,3,4,5,6" {
+		t.Errorf("expected the jobs to be listed in the order they were submitted, got %v", ids)
+	}
+}
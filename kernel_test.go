@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// kernels are the names used for each kernel in subtests and benchmarks.
+var kernels = map[string]gol.Kernel{
+	"lookup": gol.LookupKernel,
+	"column": gol.ColumnKernel,
+	"naive":  gol.NaiveKernel,
+}
+
+// TestKernels runs the check images with each kernel, checking the final alive cells and the cells flipped along
+// the way.
+func TestKernels(t *testing.T) {
+	for name, kernel := range kernels {
+		for _, size := range []int{16, 64, 512} {
+			for _, turns := range []int{0, 1, 100} {
+				expected := util.ReadAliveCells(fmt.Sprintf("check/images/%vx%vx%v.pgm", size, size, turns), size,
+					size)
+				for _, threads := range []int{1, 5} {
+					p := gol.Params{ImageWidth: size, ImageHeight: size, Turns: turns, Threads: threads,
+						FlipEvents: gol.FlipBatches, Kernel: kernel}
+					t.Run(fmt.Sprintf("%v-%dx%dx%d-%d", name, size, size, turns, threads), func(t *testing.T) {
+						assertRun(t, p, expected)
+					})
+				}
+			}
+		}
+	}
+}
+
+// TestKernelsNotSquare runs random worlds that are not square with each kernel, checking them against the sparse
+// backend, which works out every turn in a different way.
+func TestKernelsNotSquare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kernels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, size := range [][2]int{{40, 8}, {8, 40}, {33, 17}, {1, 9}, {9, 1}, {2, 3}} {
+		cells := randomCells(size[0], size[1], int64(i))
+		p := gol.Params{ImageWidth: size[0], ImageHeight: size[1], Turns: 50, Threads: 1, InitialCells: cells,
+			OutputDir: dir, Backend: gol.SparseBackend}
+		expected := finalAlive(p)
+		for name, kernel := range kernels {
+			p.Backend, p.Kernel = gol.DenseBackend, kernel
+			t.Run(fmt.Sprintf("%v-%dx%d", name, size[0], size[1]), func(t *testing.T) {
+				assertEqualBoard(t, finalAlive(p), expected, p)
+			})
+		}
+	}
+}
+
+// Performs a complete run, returning the alive cells of its FinalTurnComplete
+func finalAlive(p gol.Params) []util.Cell {
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	var alive []util.Cell
+	for event := range events {
+		if final, ok := event.(gol.FinalTurnComplete); ok {
+			alive = final.Alive
+		}
+	}
+	return alive
+}
+
+// Performs a complete run, checking its FinalTurnComplete and the world built up from its flips against the expected
+// alive cells
+func assertRun(t *testing.T, p gol.Params, expected []util.Cell) {
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	var received []gol.Event
+	for event := range events {
+		if final, ok := event.(gol.FinalTurnComplete); ok {
+			assertEqualBoard(t, final.Alive, expected, p)
+		}
+		received = append(received, event)
+	}
+	assertEqualBoard(t, applyFlips(received, p), expected, p)
+}
+
+// BenchmarkKernels compares how long 100 turns of the 512x512 image take with each kernel, without flip events.
+func BenchmarkKernels(b *testing.B) {
+	os.Stdout = nil // Disable all program output apart from benchmark results
+	for _, name := range []string{"lookup", "column", "naive"} {
+		for _, threads := range []int{1, 8} {
+			p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100, Threads: threads,
+				FlipEvents: gol.FlipNone, Kernel: kernels[name]}
+			b.Run(fmt.Sprintf("%v-%d", name, threads), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					runToEnd(p)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestNoLeakedGoroutines performs many complete runs and checks that every goroutine they started has stopped.
+// It also checks that each run ends with its final events, so that nothing is sent after the Quitting state change.
+func TestNoLeakedGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for _, size := range []int{16, 64} {
+		for _, turns := range []int{0, 1, 100} {
+			for threads := 1; threads <= 16; threads++ {
+				p := gol.Params{ImageWidth: size, ImageHeight: size, Turns: turns, Threads: threads}
+				t.Run(fmt.Sprintf("%dx%dx%d-%d", size, size, turns, threads), func(t *testing.T) {
+					events := make(chan gol.Event)
+					gol.Run(p, events, nil)
+					var last gol.Event
+					for event := range events {
+						last = event
+					}
+					state, ok := last.(gol.StateChange)
+					if !ok || state.NewState != gol.Quitting {
+						t.Fatalf("expected the last event to be the Quitting state change, got %v", last)
+					}
+				})
+			}
+		}
+	}
+	assertNoLeakedGoroutines(t, before)
+}
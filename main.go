@@ -1,13 +1,59 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
+	"os"
 	"runtime"
+	"sync"
+	"uk.ac.bris.cs/gameoflife/export"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/jobs"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/server"
+	"uk.ac.bris.cs/gameoflife/tui"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
+// parseFlipMode returns the flip mode named by the -flips flag.
+func parseFlipMode(name string) (gol.FlipMode, error) {
+	switch name {
+	case "cell":
+		return gol.FlipCells, nil
+	case "batch":
+		return gol.FlipBatches, nil
+	case "none":
+		return gol.FlipNone, nil
+	}
+	return 0, fmt.Errorf("unknown flip mode %q, expected cell, batch or none", name)
+}
+
+// parseImageFormat returns the image format named by the -format flag.
+func parseImageFormat(name string) (gol.ImageFormat, error) {
+	switch name {
+	case "pgm":
+		return gol.PGMFormat, nil
+	case "world":
+		return gol.WorldFormat, nil
+	}
+	return 0, fmt.Errorf("unknown image format %q, expected pgm or world", name)
+}
+
+// parseKernel returns the kernel named by the -kernel flag.
+func parseKernel(name string) (gol.Kernel, error) {
+	switch name {
+	case "lookup":
+		return gol.LookupKernel, nil
+	case "column":
+		return gol.ColumnKernel, nil
+	case "naive":
+		return gol.NaiveKernel, nil
+	}
+	return 0, fmt.Errorf("unknown kernel %q, expected lookup, column or naive", name)
+}
+
 // main is the function called when starting Game of Life with 'go run .'
 func main() {
 	runtime.LockOSThread()
@@ -37,15 +83,321 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.IntVar(
+		&params.TurnsPerSecond,
+		"tps",
+		0,
+		"Specify the maximum number of turns to process per second. Defaults to 0, which is unlimited.")
+
+	flag.IntVar(
+		&params.SaveEveryTurns,
+		"save-every-turns",
+		0,
+		"Specify the number of turns between automatic saves. Defaults to 0, which disables them.")
+
+	flag.DurationVar(
+		&params.SaveEvery,
+		"save-every",
+		0,
+		"Specify how often to save automatically, such as 10m. Defaults to 0, which disables it.")
+
+	flag.IntVar(
+		&params.KeepSaves,
+		"keep-saves",
+		0,
+		"Specify the number of automatic saves to keep, removing older ones. Defaults to 0, which keeps them all.")
+
+	flag.BoolVar(
+		&params.InfinitePlane,
+		"infinite",
+		false,
+		"Let cells live beyond the edges of the image, which follows them, instead of wrapping around. Defaults to false.")
+
+	sparse := flag.Bool(
+		"sparse",
+		false,
+		"Store only the alive cells, for huge worlds that are mostly empty. Defaults to false.")
+
+	flag.IntVar(
+		&params.TileSize,
+		"tile",
+		0,
+		"Specify the size of the square tiles handed out to the workers. Defaults to 0, which gives each a strip of rows.")
+
+	gifPath := flag.String(
+		"gif",
+		"",
+		"Specify a file to write an animated GIF of the run to. Disabled by default.")
+
+	framesDir := flag.String(
+		"frames",
+		"",
+		"Specify a directory to write numbered PNG frames of the run to. Disabled by default.")
+
+	exportOptions := export.DefaultOptions
+
+	flag.IntVar(
+		&exportOptions.Interval,
+		"frame-interval",
+		export.DefaultOptions.Interval,
+		"Specify the number of turns between exported frames. Defaults to 1.")
+
+	flag.IntVar(
+		&exportOptions.Scale,
+		"frame-scale",
+		export.DefaultOptions.Scale,
+		"Specify the size in pixels of each cell in exported frames. Defaults to 1.")
+
+	flag.IntVar(
+		&exportOptions.FirstTurn,
+		"frame-from",
+		export.DefaultOptions.FirstTurn,
+		"Specify the first turn to export. Defaults to 0.")
+
+	flag.IntVar(
+		&exportOptions.LastTurn,
+		"frame-to",
+		export.DefaultOptions.LastTurn,
+		"Specify the last turn to export. Defaults to -1, which exports until the end of the run.")
+
+	flag.IntVar(
+		&exportOptions.Delay,
+		"frame-delay",
+		export.DefaultOptions.Delay,
+		"Specify the delay between GIF frames in 100ths of a second. Defaults to 5.")
+
+	palette := flag.String(
+		"frame-palette",
+		"000000,ffffff",
+		"Specify the dead and alive cell colours of exported frames. Defaults to 000000,ffffff.")
+
+	headless := flag.Bool(
+		"headless",
+		false,
+		"Run without a display, printing progress instead. Defaults to false.")
+
+	terminal := flag.Bool(
+		"tui",
+		false,
+		"Render the board in the terminal instead of an SDL window. Defaults to false.")
+
+	patternsDir := flag.String(
+		"patterns",
+		"patterns",
+		"Specify a directory of RLE patterns that can be placed while paused. Defaults to patterns.")
+
+	flips := flag.String(
+		"flips",
+		"cell",
+		"Specify how changed cells are reported: cell, batch or none. Defaults to cell.")
+
+	kernel := flag.String(
+		"kernel",
+		"lookup",
+		"Specify how the next state of each cell is calculated: lookup, column or naive. Defaults to lookup.")
+
+	format := flag.String(
+		"format",
+		"pgm",
+		"Specify the format images are read and written in: pgm, or world for compressed world files. Defaults to pgm.")
+
+	serve := flag.String(
+		"serve",
+		"",
+		"Specify an address such as :8080 to serve a browser viewer on instead of opening a window. Disabled by default.")
+
+	metricsAddress := flag.String(
+		"metrics",
+		"",
+		"Specify an address such as :9090 to serve Prometheus metrics on at /metrics. Disabled by default.")
+
+	jobsAddress := flag.String(
+		"jobs",
+		"",
+		"Specify an address such as :8081 to serve the job API on, sharing -t threads between jobs. Disabled by default.")
+
+	eventsLog := flag.String(
+		"events-log",
+		"",
+		"Specify a file to write every event apart from flips to as JSON lines. Disabled by default.")
+
+	eventLogOptions := export.DefaultEventLogOptions
+
+	flag.IntVar(
+		&eventLogOptions.TurnInterval,
+		"events-log-turns",
+		export.DefaultEventLogOptions.TurnInterval,
+		"Specify the number of turns between logged TurnComplete events, 0 logs none. Defaults to 100.")
+
 	flag.Parse()
 
+	var err error
+	exportOptions.Palette, err = export.ParsePalette(*palette)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	params.FlipEvents, err = parseFlipMode(*flips)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	params.ImageFormat, err = parseImageFormat(*format)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	params.Kernel, err = parseKernel(*kernel)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if *serve != "" && params.FlipEvents == gol.FlipNone { // The viewer draws the board from the flips
+		fmt.Println("-serve needs -flips cell or batch")
+		os.Exit(2)
+	}
+	if *sparse {
+		params.Backend = gol.SparseBackend
+	}
+	if *jobsAddress == "" { // The job service has its own threads and each job its own params
+		err = params.Validate()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	if *jobsAddress != "" {
+		service, err := jobs.NewService(jobs.Config{Threads: params.Threads})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		fmt.Println("Serving the job API on", *jobsAddress)
+		err = http.ListenAndServe(*jobsAddress, service)
+		service.Close()
+		fmt.Println("Server failed:", err)
+		os.Exit(1)
+	}
+
+	if *metricsAddress != "" {
+		params.Metrics = gol.NewMetrics()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", server.MetricsHandler(params.Metrics))
+		go func() {
+			err := http.ListenAndServe(*metricsAddress, mux)
+			fmt.Println("Metrics server failed:", err)
+		}()
+	}
+
 	fmt.Println("Threads:", params.Threads)
 	fmt.Println("Width:", params.ImageWidth)
 	fmt.Println("Height:", params.ImageHeight)
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
+	bus := gol.NewBus()
+	params.Metrics.WatchBus(bus)
+	// The display and viewer coalesce the flips of each turn into one event, so that falling behind fills their buffer
+	// a turn at a time rather than a cell at a time. The exporters keep every event, as missing one would corrupt them.
+	displayEvents := bus.Subscribe(gol.SubscribeOptions{Policy: gol.Coalesce, Buffer: 1000}).Events
+	exporters := &sync.WaitGroup{}
+
+	if *gifPath != "" {
+		gifEvents := bus.Subscribe(gol.SubscribeOptions{Policy: gol.Block, Buffer: 1000}).Events
+		exporters.Add(1)
+		go func() {
+			defer exporters.Done()
+			file, err := os.Create(*gifPath)
+			if err == nil {
+				err = export.WriteGIF(file, params, gifEvents, exportOptions)
+				if closeErr := file.Close(); err == nil {
+					err = closeErr
+				}
+			} else {
+				for range gifEvents {
+				}
+			}
+			if err != nil {
+				fmt.Println("GIF export failed:", err)
+			}
+		}()
+	}
+
+	if *framesDir != "" {
+		framesEvents := bus.Subscribe(gol.SubscribeOptions{Policy: gol.Block, Buffer: 1000}).Events
+		exporters.Add(1)
+		go func() {
+			defer exporters.Done()
+			err := export.WriteFrames(*framesDir, params, framesEvents, exportOptions)
+			if err != nil {
+				fmt.Println("Frame export failed:", err)
+			}
+		}()
+	}
+
+	if *eventsLog != "" {
+		logEvents := bus.Subscribe(gol.SubscribeOptions{
+			Filter: gol.EventsOfType(gol.AliveCellsCount{}, gol.ImageOutputComplete{}, gol.StateChange{},
+				gol.SpeedChange{}, gol.TurnComplete{}, gol.FinalTurnComplete{}),
+			Policy: gol.Block,
+			Buffer: 1000,
+		}).Events
+		exporters.Add(1)
+		go func() {
+			defer exporters.Done()
+			file, err := os.Create(*eventsLog)
+			if err == nil {
+				err = export.WriteEventLog(file, logEvents, eventLogOptions)
+				if closeErr := file.Close(); err == nil {
+					err = closeErr
+				}
+			} else {
+				for range logEvents {
+				}
+			}
+			if err != nil {
+				fmt.Println("Event log failed:", err)
+			}
+		}()
+	}
 
-	gol.Run(params, events, keyPresses)
-	sdl.Start(params, events, keyPresses)
+	cellEdits := make(chan []gol.CellEdit, 10)
+	runErr := make(chan error, 1)
+	if *serve != "" {
+		commands := make(chan gol.Command, 10)
+		viewer := server.New(params, commands)
+		go viewer.Consume(bus.Subscribe(gol.SubscribeOptions{Policy: gol.Coalesce, Buffer: 1000}).Events)
+		go func() {
+			fmt.Println("Serving the viewer on", *serve)
+			err := http.ListenAndServe(*serve, viewer)
+			fmt.Println("Server failed:", err)
+		}()
+		go func() {
+			runErr <- gol.RunCommands(context.Background(), params, events, commands)
+		}()
+	} else {
+		go func() {
+			runErr <- gol.RunEditableContext(context.Background(), params, events, keyPresses, cellEdits)
+		}()
+	}
+	go bus.Forward(events)
+	switch {
+	case *headless || *serve != "":
+		tui.Headless(params, displayEvents)
+	case *terminal:
+		tui.Start(params, displayEvents, keyPresses)
+	default:
+		patterns, err := util.ReadPatternLibrary(*patternsDir)
+		if err != nil {
+			fmt.Println("Could not load patterns:", err)
+		}
+		sdl.Start(params, displayEvents, keyPresses, cellEdits, patterns)
+	}
+	exporters.Wait()
+	err = <-runErr
+	if err != nil {
+		fmt.Println("Run failed:", err)
+		os.Exit(1)
+	}
 }
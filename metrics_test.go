@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/server"
+)
+
+// TestMetrics performs a complete run with metrics and checks what they say about it afterwards, both sampled
+// directly and scraped from the metrics endpoint.
+func TestMetrics(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, FlipEvents: gol.FlipNone,
+		Metrics: gol.NewMetrics()}
+	events := make(chan gol.Event)
+	gol.Run(p, events, nil)
+	var final gol.FinalTurnComplete
+	for event := range events {
+		if e, ok := event.(gol.FinalTurnComplete); ok {
+			final = e
+		}
+	}
+
+	sample := p.Metrics.Sample()
+	if sample.CompletedTurns != 100 {
+		t.Errorf("expected 100 completed turns, got %v", sample.CompletedTurns)
+	}
+	if sample.AliveCells != len(final.Alive) {
+		t.Errorf("expected %v alive cells, got %v", len(final.Alive), sample.AliveCells)
+	}
+	if len(sample.WorkerComputeTime) != p.Threads {
+		t.Fatalf("expected compute time for %v workers, got %v", p.Threads, len(sample.WorkerComputeTime))
+	}
+	for i, duration := range sample.WorkerComputeTime {
+		if duration <= 0 {
+			t.Errorf("expected worker %v to have spent time computing, got %v", i, duration)
+		}
+	}
+	if header := int64(len("P5\n64 64\n255\n")); sample.BytesWritten != header+64*64 {
+		t.Errorf("expected %v bytes to have been written, got %v", header+64*64, sample.BytesWritten)
+	}
+	if sample.EventBacklog != 0 {
+		t.Errorf("expected no events to be waiting, got %v", sample.EventBacklog)
+	}
+
+	httpServer := httptest.NewServer(server.MetricsHandler(p.Metrics))
+	defer httpServer.Close()
+	response, err := http.Get(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE gol_completed_turns_total counter",
+		"gol_completed_turns_total 100",
+		"# TYPE gol_turns_per_second gauge",
+		"gol_worker_compute_seconds_total{worker=\"3\"} ",
+		"gol_event_backlog 0",
+		"gol_io_written_bytes_total 4109",
+	} {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("expected the metrics to contain %q, got\n%v", line, body)
+		}
+	}
+}
+
+// TestMetricsBacklog checks that the backlog is that of the slowest subscriber of the bus being watched, and that nil
+// metrics can be sampled.
+func TestMetricsBacklog(t *testing.T) {
+	var metrics *gol.Metrics
+	if sample := metrics.Sample(); sample.EventBacklog != 0 || sample.CompletedTurns != 0 {
+		t.Errorf("expected an empty sample from nil metrics, got %+v", sample)
+	}
+
+	metrics = gol.NewMetrics()
+	bus := gol.NewBus()
+	metrics.WatchBus(bus)
+	fast := bus.Subscribe(gol.SubscribeOptions{Buffer: 100})
+	slow := bus.Subscribe(gol.SubscribeOptions{Buffer: 100})
+	for turn := 1; turn <= 10; turn++ {
+		bus.Publish(gol.TurnComplete{CompletedTurns: turn})
+	}
+	for turn := 1; turn <= 10; turn++ {
+		<-fast.Events
+	}
+	// The slow subscriber may be holding one event while it waits to hand it over
+	if backlog := metrics.Sample().EventBacklog; backlog < 9 || backlog > 10 {
+		t.Errorf("expected the 10 events waiting for the slow subscriber to be reported, got %v", backlog)
+	}
+	bus.Close()
+	for range slow.Events {
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestInvalidParams checks that runs with params that cannot be run return an error straight away from both Run and
+// RunContext, closing the events channel without sending anything or leaving any goroutines behind.
+func TestInvalidParams(t *testing.T) {
+	before := runtime.NumGoroutine()
+	valid := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 1, Threads: 1}
+	for name, change := range map[string]func(p *gol.Params){
+		"zero width":       func(p *gol.Params) { p.ImageWidth = 0 },
+		"negative height":  func(p *gol.Params) { p.ImageHeight = -16 },
+		"zero threads":     func(p *gol.Params) { p.Threads = 0 },
+		"negative threads": func(p *gol.Params) { p.Threads = -1 },
+		"negative turns":   func(p *gol.Params) { p.Turns = -1 },
+		"negative speed":   func(p *gol.Params) { p.TurnsPerSecond = -1 },
+		"negative saves":   func(p *gol.Params) { p.SaveEveryTurns = -1 },
+		"negative tiles":   func(p *gol.Params) { p.TileSize = -1 },
+		"unknown kernel":   func(p *gol.Params) { p.Kernel = 10 },
+		"unknown backend":  func(p *gol.Params) { p.Backend = 10 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := valid
+			change(&p)
+			if p.Validate() == nil {
+				t.Errorf("expected %+v to be invalid", p)
+			}
+			events := make(chan gol.Event)
+			result := make(chan error)
+			go func() {
+				result <- gol.RunContext(context.Background(), p, events, nil)
+			}()
+			for event := range events {
+				t.Errorf("expected no events, got %#v", event)
+			}
+			if err := <-result; err == nil {
+				t.Error("expected the run to fail")
+			}
+			events = make(chan gol.Event)
+			if gol.Run(p, events, nil) == nil {
+				t.Error("expected Run to return an error")
+			}
+			for event := range events {
+				t.Errorf("expected no events from Run, got %#v", event)
+			}
+		})
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected %+v to be valid, got %v", valid, err)
+	}
+	assertNoLeakedGoroutines(t, before)
+}
+
+// TestDegenerateShapes runs tiny and thin worlds, with many more threads than rows, on every backend and with tiles,
+// checking them against a single thread on the sparse backend.
+func TestDegenerateShapes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shapes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ways := map[string]gol.Params{
+		"dense":  {Backend: gol.DenseBackend},
+		"naive":  {Backend: gol.DenseBackend, Kernel: gol.NaiveKernel},
+		"tiles":  {Backend: gol.DenseBackend, TileSize: 3},
+		"sparse": {Backend: gol.SparseBackend},
+	}
+	for i, size := range [][2]int{{1, 1}, {2, 2}, {3, 3}, {1, 7}, {7, 1}, {2, 9}, {16, 16}} {
+		cells := randomCells(size[0], size[1], int64(i))
+		p := gol.Params{ImageWidth: size[0], ImageHeight: size[1], Turns: 20, Threads: 1, InitialCells: cells,
+			OutputDir: dir, FlipEvents: gol.FlipBatches, Backend: gol.SparseBackend}
+		expected := finalAlive(p)
+		for name, way := range ways {
+			for _, threads := range []int{2, 64} {
+				p.Threads, p.Backend, p.Kernel, p.TileSize = threads, way.Backend, way.Kernel, way.TileSize
+				t.Run(fmt.Sprintf("%v-%dx%d-%d", name, size[0], size[1], threads), func(t *testing.T) {
+					assertRun(t, p, expected)
+				})
+			}
+		}
+	}
+}
+
+// TestMoreThreadsThanRows runs the 16x16 check image with 64 threads, and steps it on a Simulator with as many.
+func TestMoreThreadsThanRows(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 100, Threads: 64}
+	expected := util.ReadAliveCells("check/images/16x16x100.pgm", 16, 16)
+	assertEqualBoard(t, finalAlive(p), expected, p)
+
+	simulator, err := gol.NewSimulator(p, util.ReadAliveCells("images/16x16.pgm", 16, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer simulator.Close()
+	err = simulator.Step(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqualBoard(t, simulator.Snapshot().AliveCells(), expected, p)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// Checks the events of a run that was paused and resumed, failing the test if more than steps turns completed while
+// it was paused, if a state change does not alternate between paused and continuing, or if one has the wrong turn
+func assertPausedCorrectly(t *testing.T, events []gol.Event, steps int) {
+	paused := false
+	pausedAt := 0
+	lastTurn := 0
+	for _, event := range events {
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			if paused && e.CompletedTurns != pausedAt {
+				if steps == 0 {
+					t.Errorf("turn %v completed while paused at turn %v", e.CompletedTurns, pausedAt)
+				} else {
+					steps--
+					pausedAt = e.CompletedTurns
+				}
+			}
+			lastTurn = e.CompletedTurns
+		case gol.ImageOutputComplete:
+			if paused && e.CompletedTurns != pausedAt {
+				t.Errorf("saved turn %v while paused at turn %v", e.CompletedTurns, pausedAt)
+			}
+		case gol.StateChange:
+			switch e.NewState {
+			case gol.Paused:
+				if paused {
+					t.Errorf("paused at turn %v while already paused", e.CompletedTurns)
+				}
+				paused = true
+				pausedAt = e.CompletedTurns
+			case gol.Continuing:
+				if !paused {
+					t.Errorf("continued at turn %v without being paused", e.CompletedTurns)
+				} else if e.CompletedTurns != pausedAt {
+					t.Errorf("paused at turn %v but continued at turn %v", pausedAt, e.CompletedTurns)
+				}
+				paused = false
+			}
+			if e.CompletedTurns != lastTurn {
+				t.Errorf("state changed to %v at turn %v, but the last turn completed was %v", e.NewState,
+					e.CompletedTurns, lastTurn)
+			}
+		}
+	}
+}
+
+// Runs the world until it has finished, sending the key presses straight away and returning every event
+func runWithKeys(p gol.Params, keys ...rune) []gol.Event {
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, len(keys))
+	for _, key := range keys {
+		keyPresses <- key
+	}
+	gol.Run(p, events, keyPresses)
+	var received []gol.Event
+	for event := range events {
+		received = append(received, event)
+	}
+	return received
+}
+
+// Returns the state changes in a list of events
+func stateChanges(events []gol.Event) []gol.State {
+	var states []gol.State
+	for _, event := range events {
+		if state, ok := event.(gol.StateChange); ok {
+			states = append(states, state.NewState)
+		}
+	}
+	return states
+}
+
+// TestInterleavedKeys presses pause, save and quit in quick succession, and checks that every state change is sent
+// in order with the right turn numbers.
+func TestInterleavedKeys(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4}
+	for _, keys := range []string{"pp", "ppp", "psp", "pspsp", "pnnp", "pppq", "psq", "ppppq"} {
+		t.Run(keys, func(t *testing.T) {
+			keyPresses := []rune(keys)
+			if keys[len(keys)-1] != 'q' {
+				keyPresses = append(keyPresses, 'q')
+			}
+			events := runWithKeys(p, keyPresses...)
+			assertPausedCorrectly(t, events, strings.Count(keys, "n"))
+
+			var expected []gol.State
+			for _, key := range keys {
+				if key == 'p' {
+					if len(expected) > 0 && expected[len(expected)-1] == gol.Paused {
+						expected = append(expected, gol.Continuing)
+					} else {
+						expected = append(expected, gol.Paused)
+					}
+				}
+			}
+			expected = append(expected, gol.Quitting)
+			states := stateChanges(events)
+			if len(states) != len(expected) {
+				t.Fatalf("expected state changes %v, got %v", expected, states)
+			}
+			for i := range states {
+				if states[i] != expected[i] {
+					t.Fatalf("expected state changes %v, got %v", expected, states)
+				}
+			}
+		})
+	}
+}
+
+// TestQuitWhilePaused checks that quitting a paused run finishes it at the turn it was paused at.
+func TestQuitWhilePaused(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4}
+	events := runWithKeys(p, 'p', 's', 'q')
+	assertPausedCorrectly(t, events, 0)
+	pausedAt, finishedAt := -1, -2
+	for _, event := range events {
+		switch e := event.(type) {
+		case gol.StateChange:
+			if e.NewState == gol.Paused {
+				pausedAt = e.CompletedTurns
+			}
+		case gol.FinalTurnComplete:
+			finishedAt = e.CompletedTurns
+		}
+	}
+	if pausedAt != finishedAt {
+		t.Errorf("paused at turn %v but finished at turn %v", pausedAt, finishedAt)
+	}
+}
+
+// TestPauseWhileBusy pauses a run on a large image with a single worker, and checks that the pause is noticed within
+// the turn in progress and the one after it rather than waiting for the workers to go idle.
+func TestPauseWhileBusy(t *testing.T) {
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100000000, Threads: 1}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	pressed := false
+	turnsSincePress := 0
+	for event := range events {
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			if !pressed && e.CompletedTurns == 3 {
+				keyPresses <- 'p'
+				pressed = true
+			} else if pressed {
+				turnsSincePress++
+			}
+		case gol.StateChange:
+			if e.NewState == gol.Paused {
+				if turnsSincePress > 2 {
+					t.Errorf("%v turns completed before the pause was noticed", turnsSincePress)
+				}
+				keyPresses <- 's'
+				keyPresses <- 'q'
+			}
+		case gol.ImageOutputComplete:
+			if e.CompletedTurns != 3+turnsSincePress {
+				t.Errorf("expected turn %v to be saved, got %v", 3+turnsSincePress, e.CompletedTurns)
+			}
+		}
+	}
+}
+
+// TestSaveWhileRunning saves repeatedly while turns are being performed, checking that each save completes promptly.
+func TestSaveWhileRunning(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	saves := 0
+	keyPresses <- 's'
+	pressedAt := time.Now()
+	for event := range events {
+		if _, ok := event.(gol.ImageOutputComplete); ok {
+			if elapsed := time.Since(pressedAt); elapsed > time.Second {
+				t.Errorf("save took %v to complete", elapsed)
+			}
+			saves++
+			if saves < 5 {
+				keyPresses <- 's'
+				pressedAt = time.Now()
+			} else if saves == 5 {
+				keyPresses <- 'q'
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestConcurrentSaves asks for several saves at once while a run carries on, and checks that each image holds the
+// world as it was at the turn the save was asked for.
+func TestConcurrentSaves(t *testing.T) {
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100000000, Threads: 8, FlipEvents: gol.FlipNone}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 10)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunCommands(context.Background(), p, events, commands)
+	}()
+
+	replies := make(chan gol.Reply, 5)
+	for i := 0; i < cap(replies); i++ {
+		commands <- gol.Command{Type: gol.SaveCommand, Reply: replies}
+	}
+	var saves []gol.Reply
+	for len(saves) < cap(replies) {
+		select {
+		case reply := <-replies:
+			if reply.Err != nil {
+				t.Fatal(reply.Err)
+			}
+			saves = append(saves, reply)
+		case <-events:
+		}
+	}
+	commands <- gol.Command{Type: gol.ShutdownCommand}
+	for range events {
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	simulator, err := gol.NewSimulator(p, util.ReadAliveCells("images/512x512.pgm", 512, 512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer simulator.Close()
+	for _, save := range saves {
+		if save.CompletedTurns < simulator.CompletedTurns() {
+			continue // Saves of the same turn are checked once
+		}
+		err = simulator.Step(save.CompletedTurns - simulator.CompletedTurns())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Turns = save.CompletedTurns
+		saved := util.ReadAliveCells("out/"+save.Filename+".pgm", 512, 512)
+		assertEqualBoard(t, saved, simulator.Snapshot().AliveCells(), p)
+	}
+}
+
+// TestSaveOrdering asks for a save every turn while a run carries on, and checks that turns continue while they are
+// written and that their events arrive in order: each ImageOutputComplete after the TurnComplete of the turn it saved, in the
+// order the saves were asked for, and all of them before the final events.
+func TestSaveOrdering(t *testing.T) {
+	p := gol.Params{ImageWidth: 512, ImageHeight: 512, Turns: 100000000, Threads: 8, FlipEvents: gol.FlipNone}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 20)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunCommands(context.Background(), p, events, commands)
+	}()
+
+	replies := make(chan gol.Reply, cap(commands))
+	commands <- gol.Command{Type: gol.SaveCommand, Reply: replies}
+	asked := 1
+	completedTurns := 0
+	var saved []int
+	for replied := 0; replied < cap(commands)-1; { // Each save is replied to after its ImageOutputComplete is sent
+		select {
+		case reply := <-replies:
+			if reply.Err != nil {
+				t.Fatal(reply.Err)
+			}
+			replied++
+		case event := <-events:
+			switch e := event.(type) {
+			case gol.TurnComplete:
+				completedTurns = e.CompletedTurns
+				if asked < cap(commands)-1 { // Another save every turn, while the earlier ones are written
+					commands <- gol.Command{Type: gol.SaveCommand, Reply: replies}
+					asked++
+				}
+			case gol.ImageOutputComplete:
+				if e.CompletedTurns > completedTurns {
+					t.Errorf("expected turn %v to be saved after it completed, but only %v turns have",
+						e.CompletedTurns, completedTurns)
+				}
+				if len(saved) > 0 && e.CompletedTurns < saved[len(saved)-1] {
+					t.Errorf("expected saves to complete in order, got turn %v after %v", e.CompletedTurns,
+						saved[len(saved)-1])
+				}
+				saved = append(saved, e.CompletedTurns)
+			}
+		}
+	}
+	if completedTurns <= saved[0] {
+		t.Errorf("expected turns to continue while saving, got %v turns after saving turn %v", completedTurns,
+			saved[0])
+	}
+
+	commands <- gol.Command{Type: gol.SaveCommand}
+	commands <- gol.Command{Type: gol.QuitCommand}
+	var rest []gol.Event
+	for event := range events {
+		switch event.(type) {
+		case gol.ImageOutputComplete, gol.FinalTurnComplete, gol.StateChange:
+			rest = append(rest, event)
+		}
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 4 {
+		t.Fatalf("expected a save followed by the final events, got %v", rest)
+	}
+	if _, ok := rest[0].(gol.ImageOutputComplete); !ok {
+		t.Errorf("expected the save to complete before the final turn, got %v", rest[0])
+	}
+	if _, ok := rest[1].(gol.FinalTurnComplete); !ok {
+		t.Errorf("expected the final turn to complete after the save, got %v", rest[1])
+	}
+	if final, ok := rest[2].(gol.ImageOutputComplete); !ok || final.CompletedTurns < rest[0].GetCompletedTurns() {
+		t.Errorf("expected the final image to be written after the save, got %v", rest[2])
+	}
+	if state, ok := rest[3].(gol.StateChange); !ok || state.NewState != gol.Quitting {
+		t.Errorf("expected the last event to be the Quitting state change, got %v", rest[3])
+	}
+}
+
+// TestSaveThenEdit edits the world straight after asking for a save, and checks that the save still holds the world
+// from before the edit.
+func TestSaveThenEdit(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4}
+	events := make(chan gol.Event)
+	commands := make(chan gol.Command, 10)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunCommands(context.Background(), p, events, commands)
+	}()
+
+	sendCommand(t, commands, events, gol.Command{Type: gol.PauseCommand})
+	before := sendCommand(t, commands, events, gol.Command{Type: gol.SnapshotCommand}).Snapshot
+	var edits []gol.CellEdit
+	for _, cell := range before.AliveCells() {
+		edits = append(edits, gol.CellEdit{Cell: cell, Alive: false})
+	}
+	saves := make(chan gol.Reply, 1)
+	commands <- gol.Command{Type: gol.SaveCommand, Reply: saves}
+	sendCommand(t, commands, events, gol.Command{Type: gol.EditCellsCommand, Edits: edits})
+	var save gol.Reply
+	for received := false; !received; {
+		select {
+		case save = <-saves:
+			received = true
+		case <-events:
+		}
+	}
+	if save.Err != nil {
+		t.Fatal(save.Err)
+	}
+	p.Turns = before.CompletedTurns
+	assertEqualBoard(t, util.ReadAliveCells("out/"+save.Filename+".pgm", 64, 64), before.AliveCells(), p)
+	after := sendCommand(t, commands, events, gol.Command{Type: gol.SnapshotCommand}).Snapshot
+	if len(after.AliveCells()) != 0 {
+		t.Errorf("expected the edit to clear the world, got %v alive cells", len(after.AliveCells()))
+	}
+
+	commands <- gol.Command{Type: gol.ShutdownCommand}
+	for range events {
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package sdl
+
+import (
+	"fmt"
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// editor turns mouse input into cell edits for the distributor while the simulation is paused.
+// Left click or drag toggles cells, right click places the selected pattern with its top left corner at the cursor.
+type editor struct {
+	w          *Window
+	cellEdits  chan<- []gol.CellEdit
+	patterns   []util.Pattern
+	selected   int
+	paused     bool
+	painting   bool
+	paintAlive bool
+	lastCell   util.Cell
+	pending    []gol.CellEdit // Edits waiting for room in cellEdits, in the order they were made
+}
+
+func newEditor(w *Window, cellEdits chan<- []gol.CellEdit, patterns []util.Pattern) *editor {
+	return &editor{w: w, cellEdits: cellEdits, patterns: patterns}
+}
+
+// Queues edits for the distributor, doing nothing if edits are not supported by the caller
+func (e *editor) send(edits []gol.CellEdit) {
+	if e.cellEdits != nil && len(edits) > 0 {
+		e.pending = append(e.pending, edits...)
+		e.flush()
+	}
+}
+
+// flush sends the queued edits as one batch if there is room for them, so the SDL loop never waits on the
+// distributor. It is called on every iteration of the loop to retry edits that did not fit.
+func (e *editor) flush() {
+	if len(e.pending) == 0 {
+		return
+	}
+	select {
+	case e.cellEdits <- e.pending:
+		e.pending = nil
+	default:
+	}
+}
+
+// Returns edits setting every cell on the line between two cells, so fast drags do not leave gaps
+func lineEdits(from, to util.Cell, alive bool) []gol.CellEdit {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	steps := dx
+	if steps < 0 {
+		steps = -steps
+	}
+	if dy > steps {
+		steps = dy
+	} else if -dy > steps {
+		steps = -dy
+	}
+	var edits []gol.CellEdit
+	for i := 1; i <= steps; i++ {
+		cell := util.Cell{X: from.X + dx*i/steps, Y: from.Y + dy*i/steps}
+		edits = append(edits, gol.CellEdit{Cell: cell, Alive: alive})
+	}
+	return edits
+}
+
+// setPaused is called whenever the distributor reports a change of state.
+func (e *editor) setPaused(paused bool) {
+	e.paused = paused
+	if !paused {
+		e.painting = false
+	}
+}
+
+// selectPattern moves the selection through the pattern library by the given offset, wrapping at either end.
+func (e *editor) selectPattern(offset int) {
+	if len(e.patterns) == 0 {
+		fmt.Println("No patterns loaded")
+		return
+	}
+	e.selected = ((e.selected+offset)%len(e.patterns) + len(e.patterns)) % len(e.patterns)
+	fmt.Println("Selected pattern", e.patterns[e.selected].Name)
+}
+
+// handleMouseButton starts or stops painting, or places a pattern.
+func (e *editor) handleMouseButton(event *sdl.MouseButtonEvent) {
+	if event.Type == sdl.MOUSEBUTTONUP {
+		if event.Button == sdl.BUTTON_LEFT {
+			e.painting = false
+		}
+		return
+	}
+	if !e.paused {
+		return
+	}
+	cell, ok := e.w.CellAt(event.X, event.Y)
+	if !ok {
+		return
+	}
+	switch event.Button {
+	case sdl.BUTTON_LEFT:
+		e.painting = true
+		e.paintAlive = !e.w.IsAlive(cell.X, cell.Y)
+		e.lastCell = cell
+		e.send([]gol.CellEdit{{Cell: cell, Alive: e.paintAlive}})
+	case sdl.BUTTON_RIGHT:
+		if len(e.patterns) == 0 {
+			return
+		}
+		pattern := e.patterns[e.selected]
+		edits := make([]gol.CellEdit, len(pattern.Cells))
+		for i, patternCell := range pattern.Cells {
+			edits[i] = gol.CellEdit{Cell: util.Cell{X: cell.X + patternCell.X, Y: cell.Y + patternCell.Y}, Alive: true}
+		}
+		e.send(edits)
+	}
+}
+
+// handleMouseMotion continues painting along the path of the cursor.
+func (e *editor) handleMouseMotion(event *sdl.MouseMotionEvent) {
+	if !e.painting || !e.paused {
+		return
+	}
+	cell, ok := e.w.CellAt(event.X, event.Y)
+	if !ok || cell == e.lastCell {
+		return
+	}
+	e.send(lineEdits(e.lastCell, cell, e.paintAlive))
+	e.lastCell = cell
+}
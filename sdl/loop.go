@@ -4,15 +4,21 @@ import (
 	"fmt"
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
-func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, cellEdits chan<- []gol.CellEdit,
+	patterns []util.Pattern) {
 	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
+	w.SetSpeed(p.TurnsPerSecond)
+	ed := newEditor(w, cellEdits, patterns)
+	panning := false
 
 sdlLoop:
 	for {
 		event := w.PollEvent()
 		if event != nil {
+			viewChanged := false
 			switch e := event.(type) {
 			case *sdl.KeyboardEvent:
 				switch e.Keysym.Sym {
@@ -24,9 +30,69 @@ sdlLoop:
 					keyPresses <- 'q'
 				case sdl.K_k:
 					keyPresses <- 'k'
+				case sdl.K_n:
+					keyPresses <- 'n'
+				case sdl.K_EQUALS, sdl.K_PLUS, sdl.K_KP_PLUS:
+					keyPresses <- '+'
+				case sdl.K_MINUS, sdl.K_KP_MINUS:
+					keyPresses <- '-'
+				case sdl.K_m:
+					keyPresses <- 'm'
+				case sdl.K_r:
+					w.CycleRenderInterval()
+				case sdl.K_LEFTBRACKET:
+					ed.selectPattern(-1)
+				case sdl.K_RIGHTBRACKET:
+					ed.selectPattern(1)
+				case sdl.K_f:
+					w.FitToWindow()
+					viewChanged = true
+				case sdl.K_1:
+					w.ActualSize()
+					viewChanged = true
+				case sdl.K_g:
+					w.ToggleGrid()
+					viewChanged = true
+				case sdl.K_UP:
+					w.Pan(0, w.viewHeight/8)
+					viewChanged = true
+				case sdl.K_DOWN:
+					w.Pan(0, -w.viewHeight/8)
+					viewChanged = true
+				case sdl.K_LEFT:
+					w.Pan(w.viewWidth/8, 0)
+					viewChanged = true
+				case sdl.K_RIGHT:
+					w.Pan(-w.viewWidth/8, 0)
+					viewChanged = true
 				}
+			case *sdl.MouseButtonEvent:
+				// The middle button always pans, the left button pans unless it is being used to edit cells
+				if e.Button == sdl.BUTTON_MIDDLE || (e.Button == sdl.BUTTON_LEFT && !ed.paused) {
+					panning = e.Type == sdl.MOUSEBUTTONDOWN
+				}
+				ed.handleMouseButton(e)
+			case *sdl.MouseMotionEvent:
+				if panning {
+					w.Pan(e.XRel, e.YRel)
+					viewChanged = true
+				}
+				ed.handleMouseMotion(e)
+			case *sdl.MouseWheelEvent:
+				x, y, _ := sdl.GetMouseState()
+				w.ZoomAt(int(e.Y), x, y)
+				viewChanged = true
+			case *sdl.WindowEvent:
+				if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED {
+					w.Resize(e.Data1, e.Data2)
+					viewChanged = true
+				}
+			}
+			if viewChanged {
+				w.RenderFrame()
 			}
 		}
+		ed.flush()
 		select {
 		case event, ok := <-events:
 			if !ok {
@@ -36,8 +102,22 @@ sdlLoop:
 			switch e := event.(type) {
 			case gol.CellFlipped:
 				w.FlipPixel(e.Cell.X, e.Cell.Y)
-			case gol.TurnComplete:
+			case gol.CellsFlipped:
+				w.FlipPixels(e.Cells)
+			case gol.ViewMoved:
+				w.MoveView(e.Origin, e.Alive)
+			case gol.CellsEdited:
 				w.RenderFrame()
+			case gol.TurnComplete:
+				if ed.paused || e.CompletedTurns%w.RenderInterval() == 0 {
+					w.RenderFrame()
+				}
+			case gol.SpeedChange:
+				w.SetSpeed(e.TurnsPerSecond)
+				fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
+			case gol.StateChange:
+				ed.setPaused(e.NewState == gol.Paused)
+				fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
 			default:
 				if len(event.String()) > 0 {
 					fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
@@ -1,44 +1,103 @@
 package sdl
 
 import (
+	"fmt"
 	"github.com/veandco/go-sdl2/sdl"
+	"math"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
+// zoomLevels are the number of window pixels used to draw each cell.
+// Levels below 1 draw one in every few cells so that huge worlds can be viewed whole.
+var zoomLevels = []float64{1.0 / 64, 1.0 / 32, 1.0 / 16, 1.0 / 8, 1.0 / 4, 1.0 / 2, 1, 2, 3, 4, 6, 8, 12, 16, 24, 32, 48, 64}
+
+// actualSize is the index of the 1:1 zoom level.
+const actualSize = 6
+
+// renderIntervals are the choices for how many turns pass between frames being rendered.
+var renderIntervals = []int{1, 2, 5, 10, 50, 100}
+
+// gridMinimumZoom is the smallest number of pixels per cell at which the grid overlay is drawn.
+const gridMinimumZoom = 6
+
+// The colours used to draw the view, in ARGB byte order.
+var (
+	aliveColour   = [4]byte{0xFF, 0xFF, 0xFF, 0xFF}
+	deadColour    = [4]byte{0x00, 0x00, 0x00, 0xFF}
+	outsideColour = [4]byte{0x30, 0x30, 0x30, 0xFF}
+	gridColour    = [4]byte{0x40, 0x40, 0x40, 0xFF}
+)
+
 type Window struct {
-	Width, Height int32
+	Width, Height int32 // The size of the world in cells
 	window        *sdl.Window
 	renderer      *sdl.Renderer
 	texture       *sdl.Texture
-	pixels        []byte
+	cells         []byte // The state of every cell in the world, non-zero if alive
+	pixels        []byte // The pixels of the visible region of the world
+	viewWidth     int32  // The size of the window in pixels
+	viewHeight    int32
+	zoom          int     // Index into zoomLevels
+	originX       float64 // The world coordinates of the top left corner of the window
+	originY       float64
+	showGrid      bool
+	planeOrigin   util.Cell // Where the top left cell of the world is on an infinite plane
+	speed         int       // The speed limit in turns per second, 0 if unlimited
+	renderEvery   int       // Index into renderIntervals
 }
 
 func filterEvent(e sdl.Event, userdata interface{}) bool {
-	return e.GetType() == sdl.KEYDOWN || e.GetType() == sdl.QUIT
+	switch e.GetType() {
+	case sdl.KEYDOWN, sdl.QUIT, sdl.MOUSEBUTTONDOWN, sdl.MOUSEBUTTONUP, sdl.MOUSEMOTION, sdl.MOUSEWHEEL, sdl.WINDOWEVENT:
+		return true
+	}
+	return false
+}
+
+// Returns a starting window size that shows small worlds at an integer scale and fits large worlds on screen
+func initialWindowSize(width, height int32) (int32, int32) {
+	maxWidth, maxHeight := int32(1024), int32(768)
+	mode, err := sdl.GetCurrentDisplayMode(0)
+	if err == nil {
+		maxWidth, maxHeight = mode.W*4/5, mode.H*4/5
+	}
+	scale := int32(1)
+	for width*(scale+1) <= 512 && height*(scale+1) <= 512 {
+		scale++
+	}
+	windowWidth, windowHeight := width*scale, height*scale
+	if windowWidth > maxWidth {
+		windowWidth = maxWidth
+	}
+	if windowHeight > maxHeight {
+		windowHeight = maxHeight
+	}
+	return windowWidth, windowHeight
 }
 
 func NewWindow(width, height int32) *Window {
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	util.Check(err)
-	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, width, height, sdl.WINDOW_SHOWN)
+	windowWidth, windowHeight := initialWindowSize(width, height)
+	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, windowWidth,
+		windowHeight, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	util.Check(err)
+	window.SetMinimumSize(64, 64)
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.WINDOW_SHOWN)
 	util.Check(err)
-	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "linear")
-	err = renderer.SetLogicalSize(width, height)
-	util.Check(err)
-	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, width, height)
-	util.Check(err)
 
 	sdl.SetEventFilterFunc(filterEvent, nil)
-	return &Window{
-		width,
-		height,
-		window,
-		renderer,
-		texture,
-		make([]byte, width*height*4),
+	w := &Window{
+		Width:    width,
+		Height:   height,
+		window:   window,
+		renderer: renderer,
+		cells:    make([]byte, width*height),
+		showGrid: true,
 	}
+	w.Resize(windowWidth, windowHeight)
+	w.FitToWindow()
+	return w
 }
 
 func (w *Window) Destroy() {
@@ -51,8 +110,110 @@ func (w *Window) Destroy() {
 	sdl.Quit()
 }
 
+// Resize recreates the texture to match a new window size, keeping the same part of the world in view.
+func (w *Window) Resize(viewWidth, viewHeight int32) {
+	if w.texture != nil {
+		err := w.texture.Destroy()
+		util.Check(err)
+	}
+	texture, err := w.renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, viewWidth,
+		viewHeight)
+	util.Check(err)
+	w.texture = texture
+	w.viewWidth, w.viewHeight = viewWidth, viewHeight
+	w.pixels = make([]byte, viewWidth*viewHeight*4)
+}
+
+// Returns the number of pixels used to draw each cell at the current zoom level
+func (w *Window) scale() float64 {
+	return zoomLevels[w.zoom]
+}
+
+// Updates the title so that it shows the current zoom level, speed and render interval
+func (w *Window) updateTitle() {
+	title := "GOL GUI"
+	scale := w.scale()
+	if scale >= 1 {
+		title += fmt.Sprintf(" - %vx", scale)
+	} else {
+		title += fmt.Sprintf(" - 1/%vx", 1/scale)
+	}
+	if w.planeOrigin != (util.Cell{}) {
+		title += fmt.Sprintf(" - at %v,%v", w.planeOrigin.X, w.planeOrigin.Y)
+	}
+	if w.speed == 0 {
+		title += " - max speed"
+	} else {
+		title += fmt.Sprintf(" - %v turns/s", w.speed)
+	}
+	if w.RenderInterval() > 1 {
+		title += fmt.Sprintf(" - rendering every %v turns", w.RenderInterval())
+	}
+	w.window.SetTitle(title)
+}
+
+// SetSpeed shows a new speed limit in the title, where 0 means unlimited.
+func (w *Window) SetSpeed(turnsPerSecond int) {
+	w.speed = turnsPerSecond
+	w.updateTitle()
+}
+
+// RenderInterval returns the number of turns between frames being rendered.
+func (w *Window) RenderInterval() int {
+	return renderIntervals[w.renderEvery]
+}
+
+// CycleRenderInterval moves on to the next render interval, wrapping back round to rendering every turn.
+func (w *Window) CycleRenderInterval() {
+	w.renderEvery = (w.renderEvery + 1) % len(renderIntervals)
+	w.updateTitle()
+}
+
+// Returns the world coordinate at the edge of each pixel along one axis, or -1 if the pixel is outside the world
+func visibleCells(origin, scale float64, pixels, cells int32) []int32 {
+	coordinates := make([]int32, pixels)
+	for p := range coordinates {
+		cell := int32(math.Floor(origin + (float64(p)+0.5)/scale))
+		if scale >= 1 {
+			cell = int32(math.Floor(origin + float64(p)/scale))
+		}
+		if cell < 0 || cell >= cells {
+			cell = -1
+		}
+		coordinates[p] = cell
+	}
+	return coordinates
+}
+
+// Draws the visible region of the world into the pixel buffer
+func (w *Window) drawView() {
+	scale := w.scale()
+	columns := visibleCells(w.originX, scale, w.viewWidth, w.Width)
+	rows := visibleCells(w.originY, scale, w.viewHeight, w.Height)
+	grid := w.showGrid && scale >= gridMinimumZoom
+	for py, cellY := range rows {
+		rowGrid := grid && py > 0 && rows[py-1] != cellY
+		line := w.pixels[int32(py)*w.viewWidth*4 : (int32(py)+1)*w.viewWidth*4]
+		for px, cellX := range columns {
+			colour := &outsideColour
+			if cellX >= 0 && cellY >= 0 {
+				switch {
+				case rowGrid || (grid && px > 0 && columns[px-1] != cellX):
+					colour = &gridColour
+				case w.cells[cellY*w.Width+cellX] != 0:
+					colour = &aliveColour
+				default:
+					colour = &deadColour
+				}
+			}
+			copy(line[px*4:px*4+4], colour[:])
+		}
+	}
+}
+
 func (w *Window) RenderFrame() {
-	err := w.texture.Update(nil, w.pixels, int(w.Width*4))
+	w.drawView()
+	err := w.texture.Update(nil, w.pixels, int(w.viewWidth*4))
 	util.Check(err)
 	err = w.renderer.Clear()
 	util.Check(err)
@@ -65,24 +226,94 @@ func (w *Window) PollEvent() sdl.Event {
 	return sdl.PollEvent()
 }
 
+// ZoomAt moves the given number of zoom levels in (positive) or out (negative), keeping the cell under the
+// given window coordinates in place.
+func (w *Window) ZoomAt(levels int, x, y int32) {
+	zoom := w.zoom + levels
+	if zoom < 0 {
+		zoom = 0
+	} else if zoom >= len(zoomLevels) {
+		zoom = len(zoomLevels) - 1
+	}
+	worldX := w.originX + float64(x)/w.scale()
+	worldY := w.originY + float64(y)/w.scale()
+	w.zoom = zoom
+	w.originX = worldX - float64(x)/w.scale()
+	w.originY = worldY - float64(y)/w.scale()
+	w.updateTitle()
+}
+
+// ActualSize switches to drawing each cell as a single pixel, keeping the centre of the view in place.
+func (w *Window) ActualSize() {
+	w.ZoomAt(actualSize-w.zoom, w.viewWidth/2, w.viewHeight/2)
+}
+
+// FitToWindow picks the largest zoom level at which the whole world is visible and centres it.
+func (w *Window) FitToWindow() {
+	w.zoom = 0
+	for zoom, scale := range zoomLevels {
+		if float64(w.Width)*scale <= float64(w.viewWidth) && float64(w.Height)*scale <= float64(w.viewHeight) {
+			w.zoom = zoom
+		}
+	}
+	w.originX = float64(w.Width)/2 - float64(w.viewWidth)/2/w.scale()
+	w.originY = float64(w.Height)/2 - float64(w.viewHeight)/2/w.scale()
+	w.updateTitle()
+}
+
+// Pan moves the view by the given number of window pixels.
+func (w *Window) Pan(dx, dy int32) {
+	w.originX -= float64(dx) / w.scale()
+	w.originY -= float64(dy) / w.scale()
+}
+
+// ToggleGrid shows or hides the grid drawn between cells when zoomed in.
+func (w *Window) ToggleGrid() {
+	w.showGrid = !w.showGrid
+}
+
 func (w *Window) SetPixel(x, y int) {
-	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = 0xFF
-	w.pixels[4*(y*width+x)+1] = 0xFF
-	w.pixels[4*(y*width+x)+2] = 0xFF
-	w.pixels[4*(y*width+x)+3] = 0xFF
+	w.cells[y*int(w.Width)+x] = 0xFF
 }
 
 func (w *Window) FlipPixel(x, y int) {
-	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = ^w.pixels[4*(y*width+x)+0]
-	w.pixels[4*(y*width+x)+1] = ^w.pixels[4*(y*width+x)+1]
-	w.pixels[4*(y*width+x)+2] = ^w.pixels[4*(y*width+x)+2]
-	w.pixels[4*(y*width+x)+3] = ^w.pixels[4*(y*width+x)+3]
+	w.cells[y*int(w.Width)+x] = ^w.cells[y*int(w.Width)+x]
+}
+
+// FlipPixels inverts every cell in a batch, as sent in a CellsFlipped event.
+func (w *Window) FlipPixels(cells []util.Cell) {
+	for _, cell := range cells {
+		w.cells[cell.Y*int(w.Width)+cell.X] = ^w.cells[cell.Y*int(w.Width)+cell.X]
+	}
+}
+
+func (w *Window) IsAlive(x, y int) bool {
+	return w.cells[y*int(w.Width)+x] != 0
+}
+
+// CellAt returns the cell under the given window coordinates, and false if they are outside the world.
+func (w *Window) CellAt(x, y int32) (util.Cell, bool) {
+	cellX := int(math.Floor(w.originX + float64(x)/w.scale()))
+	cellY := int(math.Floor(w.originY + float64(y)/w.scale()))
+	if cellX < 0 || cellY < 0 || cellX >= int(w.Width) || cellY >= int(w.Height) {
+		return util.Cell{}, false
+	}
+	return util.Cell{X: cellX, Y: cellY}, true
+}
+
+// MoveView replaces every cell with those alive where the world has moved to on an infinite plane, as sent in a
+// ViewMoved event, and shows where it is in the title.
+func (w *Window) MoveView(origin util.Cell, alive []util.Cell) {
+	w.ClearPixels()
+	for _, cell := range alive {
+		w.SetPixel(cell.X, cell.Y)
+	}
+	w.planeOrigin = origin
+	w.updateTitle()
 }
 
 func (w *Window) ClearPixels() {
-	for i := range w.pixels {
-		w.pixels[i] = 0
+	for i := range w.cells {
+		w.cells[i] = 0
 	}
 }
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// MetricsHandler serves the metrics of a run in the Prometheus text format, so that existing monitoring can scrape it.
+func MetricsHandler(m *gol.Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteMetrics(w, m.Sample())
+	})
+}
+
+// WriteMetrics writes a sample of a run's metrics in the Prometheus text format.
+func WriteMetrics(w io.Writer, sample gol.MetricsSample) error {
+	metric := func(name string, kind string, help string, values ...string) error {
+		_, err := fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+		for _, value := range values {
+			if err == nil {
+				_, err = fmt.Fprintf(w, "%v%v\n", name, value)
+			}
+		}
+		return err
+	}
+	float := func(value float64) string {
+		return " " + strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	workers := make([]string, len(sample.WorkerComputeTime))
+	for i, duration := range sample.WorkerComputeTime {
+		workers[i] = fmt.Sprintf(`{worker="%v"}`, i) + float(duration.Seconds())
+	}
+	err := metric("gol_completed_turns_total", "counter", "The number of turns completed.",
+		" "+strconv.Itoa(sample.CompletedTurns))
+	if err == nil {
+		err = metric("gol_turns_per_second", "gauge", "The number of turns completed per second.",
+			float(sample.TurnsPerSecond))
+	}
+	if err == nil {
+		err = metric("gol_alive_cells", "gauge", "The number of alive cells, updated every 2 seconds.",
+			" "+strconv.Itoa(sample.AliveCells))
+	}
+	if err == nil {
+		err = metric("gol_worker_compute_seconds_total", "counter",
+			"The time each worker has spent calculating its part of the world.", workers...)
+	}
+	if err == nil {
+		err = metric("gol_event_backlog", "gauge", "The number of events waiting to be received.",
+			" "+strconv.Itoa(sample.EventBacklog))
+	}
+	if err == nil {
+		err = metric("gol_io_written_bytes_total", "counter", "The number of bytes of images written.",
+			" "+strconv.FormatInt(sample.BytesWritten, 10))
+	}
+	return err
+}
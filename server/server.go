@@ -0,0 +1,353 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"sync"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// clientBuffer is the number of messages that can wait to be written to a browser before it is treated as slow.
+const clientBuffer = 256
+
+// writeTimeout is how long writing a single message to a browser may take before it is disconnected.
+const writeTimeout = 10 * time.Second
+
+// Server serves a viewer for a run to browsers, streaming the world to them over WebSocket.
+// It keeps its own copy of the world, built from the run's events, so every browser that connects is sent a snapshot
+// of the last completed turn followed by the cells that change each turn.
+type Server struct {
+	params   gol.Params
+	commands chan<- gol.Command
+	mux      *http.ServeMux
+	upgrader websocket.Upgrader
+
+	mutex    sync.Mutex
+	world    [][]bool
+	flipped  map[util.Cell]bool // Cells flipped since the last completed turn, each an odd number of times
+	turn     int
+	alive    int
+	origin   util.Cell // Where the top left cell of the world is on an infinite plane
+	state    gol.State
+	finished bool
+	clients  map[*client]bool
+}
+
+// client is a single connected browser.
+type client struct {
+	conn  *websocket.Conn
+	send  chan []byte
+	stale bool // Set when messages were dropped because the browser fell behind, so it needs a fresh snapshot
+}
+
+// Status is the JSON returned by the /status endpoint.
+type Status struct {
+	Turn       int    `json:"turn"`
+	AliveCount int    `json:"aliveCount"`
+	State      string `json:"state"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Finished   bool   `json:"finished"`
+}
+
+// snapshot is the message sent to a browser when it connects or has fallen behind, holding the state of the run as
+// the browser may have missed the StateChange.
+type snapshot struct {
+	Type   string   `json:"type"`
+	Turn   int      `json:"turn"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Alive  [][2]int `json:"alive"`
+	State  string   `json:"state"`
+	Origin [2]int   `json:"origin"`
+}
+
+// diff is the message sent to every browser once a turn has completed, holding the cells that changed.
+type diff struct {
+	Type  string   `json:"type"`
+	Turn  int      `json:"turn"`
+	Cells [][2]int `json:"cells"`
+}
+
+// command is a message sent by a browser to control the run.
+type command struct {
+	Command string `json:"command"`
+}
+
+// browserCommands are the commands a browser is allowed to send.
+var browserCommands = map[string]gol.CommandType{
+	"pause":  gol.PauseCommand,
+	"resume": gol.ResumeCommand,
+	"step":   gol.StepCommand,
+	"save":   gol.SaveCommand,
+	"quit":   gol.QuitCommand,
+}
+
+// New returns a server for a run with the given parameters, which passes commands from browsers on to commands.
+// Events must be passed to Consume, from a subscription made before the run started, for the world to be shown.
+func New(p gol.Params, commands chan<- gol.Command) *Server {
+	world := make([][]bool, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]bool, p.ImageWidth)
+	}
+	s := &Server{
+		params:   p,
+		commands: commands,
+		mux:      http.NewServeMux(),
+		world:    world,
+		flipped:  make(map[util.Cell]bool),
+		state:    gol.Executing,
+		clients:  make(map[*client]bool),
+	}
+	s.mux.HandleFunc("/", s.serveViewer)
+	s.mux.HandleFunc("/status", s.serveStatus)
+	s.mux.HandleFunc("/ws", s.serveWebSocket)
+	if p.Metrics != nil {
+		s.mux.Handle("/metrics", MetricsHandler(p.Metrics))
+	}
+	return s
+}
+
+// ServeHTTP serves the viewer at /, the current status as JSON at /status and the WebSocket stream at /ws, along with
+// the run's metrics at /metrics if it has any.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Consume updates the world from a run's events until the channel is closed, sending them on to every browser.
+func (s *Server) Consume(events <-chan gol.Event) {
+	for event := range events {
+		s.mutex.Lock()
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			s.flip(e.Cell)
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				s.flip(cell)
+			}
+		case gol.ViewMoved:
+			s.moveView(e)
+		case gol.TurnComplete:
+			s.completeTurn(e.CompletedTurns)
+		case gol.CellsEdited: // Sent like a turn, so that browsers show the edited cells straight away
+			s.completeTurn(e.CompletedTurns)
+		case gol.FinalTurnComplete: // Not passed on as it holds every alive cell, which browsers already have
+		case gol.StateChange:
+			s.state = e.NewState
+			s.broadcastEvent(event)
+		default:
+			s.broadcastEvent(event)
+		}
+		s.mutex.Unlock()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.finished = true
+	for c := range s.clients {
+		close(c.send)
+		delete(s.clients, c)
+	}
+}
+
+// Records that a cell has flipped, which is applied to the world once the turn completes
+// The mutex must be held
+func (s *Server) flip(cell util.Cell) {
+	if s.flipped[cell] {
+		delete(s.flipped, cell)
+	} else {
+		s.flipped[cell] = true
+	}
+}
+
+// Applies the cells flipped during a turn to the world and sends them to every browser
+// The mutex must be held
+func (s *Server) completeTurn(turn int) {
+	cells := make([][2]int, 0, len(s.flipped))
+	for cell := range s.flipped {
+		s.world[cell.Y][cell.X] = !s.world[cell.Y][cell.X]
+		if s.world[cell.Y][cell.X] {
+			s.alive++
+		} else {
+			s.alive--
+		}
+		cells = append(cells, [2]int{cell.X, cell.Y})
+	}
+	s.flipped = make(map[util.Cell]bool)
+	s.turn = turn
+	message, err := json.Marshal(diff{Type: "diff", Turn: turn, Cells: cells})
+	if err == nil {
+		s.broadcast(message, true)
+	}
+}
+
+// Replaces the world with the cells where it has moved to on an infinite plane, sending every browser a snapshot
+// The mutex must be held
+func (s *Server) moveView(moved gol.ViewMoved) {
+	for _, row := range s.world {
+		for x := range row {
+			row[x] = false
+		}
+	}
+	for _, cell := range moved.Alive {
+		s.world[cell.Y][cell.X] = true
+	}
+	s.alive = len(moved.Alive)
+	s.flipped = make(map[util.Cell]bool)
+	s.origin = moved.Origin
+	s.turn = moved.CompletedTurns
+	s.broadcast(s.snapshot(), false)
+}
+
+// Returns the world as it was after the last completed turn, encoded as a snapshot message
+// The mutex must be held
+func (s *Server) snapshot() []byte {
+	alive := make([][2]int, 0, s.alive)
+	for y, row := range s.world {
+		for x, cell := range row {
+			if cell {
+				alive = append(alive, [2]int{x, y})
+			}
+		}
+	}
+	message, _ := json.Marshal(snapshot{
+		Type:   "snapshot",
+		Turn:   s.turn,
+		Width:  s.params.ImageWidth,
+		Height: s.params.ImageHeight,
+		Alive:  alive,
+		State:  s.state.String(),
+		Origin: [2]int{s.origin.X, s.origin.Y},
+	})
+	return message
+}
+
+// Sends an event to every browser using its JSON encoding
+// The mutex must be held
+func (s *Server) broadcastEvent(event gol.Event) {
+	message, err := json.Marshal(event)
+	if err == nil {
+		s.broadcast(message, false)
+	}
+}
+
+// Sends a message to every browser without waiting for any of them
+// A browser that has fallen behind misses messages until there is room for a fresh snapshot, which already includes
+// the changes in a diff sent at the same time
+// The mutex must be held
+func (s *Server) broadcast(message []byte, isDiff bool) {
+	for c := range s.clients {
+		if c.stale {
+			if len(c.send) > clientBuffer/2 {
+				continue
+			}
+			c.send <- s.snapshot()
+			c.stale = false
+			if isDiff {
+				continue
+			}
+		}
+		select {
+		case c.send <- message:
+		default:
+			c.stale = true
+		}
+	}
+}
+
+// Serves the HTML viewer
+func (s *Server) serveViewer(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(viewer))
+}
+
+// Serves the current turn, number of alive cells and state of the run as JSON
+func (s *Server) serveStatus(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	status := Status{
+		Turn:       s.turn,
+		AliveCount: s.alive,
+		State:      s.state.String(),
+		Width:      s.params.ImageWidth,
+		Height:     s.params.ImageHeight,
+		Finished:   s.finished,
+	}
+	s.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// Upgrades a request to a WebSocket, sends the browser a snapshot, then streams the run to it and reads its commands
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has already replied with an error
+	}
+	c := &client{conn: conn, send: make(chan []byte, clientBuffer)}
+	s.mutex.Lock()
+	c.send <- s.snapshot()
+	if s.finished {
+		close(c.send)
+	} else {
+		s.clients[c] = true
+	}
+	s.mutex.Unlock()
+	go s.readCommands(c)
+	s.writeMessages(c)
+}
+
+// Writes messages to a browser until its channel is closed or writing fails, then closes the connection
+func (s *Server) writeMessages(c *client) {
+	defer c.conn.Close()
+	for message := range c.send {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		err := c.conn.WriteMessage(websocket.TextMessage, message)
+		if err != nil {
+			s.disconnect(c)
+			for range c.send { // Drained until Consume or disconnect closes it
+			}
+			return
+		}
+	}
+	_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// Stops sending messages to a browser
+func (s *Server) disconnect(c *client) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.clients[c] {
+		delete(s.clients, c)
+		close(c.send)
+	}
+}
+
+// Reads commands from a browser until it disconnects, passing the ones it is allowed to send on to the run
+func (s *Server) readCommands(c *client) {
+	defer s.disconnect(c)
+	for {
+		var message command
+		err := c.conn.ReadJSON(&message)
+		if err != nil {
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError: // Ignore messages that are not commands
+				continue
+			}
+			return
+		}
+		commandType, ok := browserCommands[message.Command]
+		if !ok {
+			continue
+		}
+		select {
+		case s.commands <- gol.Command{Type: commandType}:
+		case <-time.After(writeTimeout): // The run has finished or is not taking commands
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+// viewer is the page served at /, which draws the world on a canvas from the WebSocket stream at /ws.
+const viewer = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Game of Life</title>
+<style>
+body { background: #202020; color: #e0e0e0; font-family: sans-serif; margin: 1em; }
+canvas { image-rendering: pixelated; border: 1px solid #404040; width: 100%; max-width: 90vh; }
+button { margin-right: 0.5em; }
+#status { margin: 0.5em 0; }
+</style>
+</head>
+<body>
+<div>
+<button data-command="pause">Pause</button>
+<button data-command="resume">Resume</button>
+<button data-command="step">Step</button>
+<button data-command="save">Save</button>
+<button data-command="quit">Quit</button>
+</div>
+<div id="status">Connecting...</div>
+<canvas id="world"></canvas>
+<script>
+"use strict";
+const canvas = document.getElementById("world");
+const context = canvas.getContext("2d");
+const status = document.getElementById("status");
+let image = null;
+let turn = 0;
+let state = "";
+let origin = [0, 0];
+let message = "";
+let drawing = false;
+
+function draw() {
+	drawing = false;
+	if (image) {
+		context.putImageData(image, 0, 0);
+	}
+	showStatus();
+}
+
+function showStatus() {
+	const at = origin[0] || origin[1] ? " - at " + origin[0] + "," + origin[1] : "";
+	status.textContent = "Completed Turns " + turn + at + (state ? " - " + state : "") + (message ? " - " + message : "");
+}
+
+function setCell(x, y, alive) {
+	const i = (y * image.width + x) * 4;
+	const value = alive ? 255 : 0;
+	image.data[i] = value;
+	image.data[i + 1] = value;
+	image.data[i + 2] = value;
+	image.data[i + 3] = 255;
+}
+
+function flipCell(x, y) {
+	const i = (y * image.width + x) * 4;
+	setCell(x, y, image.data[i] === 0);
+}
+
+const socket = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+socket.onmessage = function (event) {
+	const data = JSON.parse(event.data);
+	switch (data.type) {
+	case "snapshot":
+		canvas.width = data.width;
+		canvas.height = data.height;
+		image = context.createImageData(data.width, data.height);
+		for (let y = 0; y < data.height; y++) {
+			for (let x = 0; x < data.width; x++) {
+				setCell(x, y, false);
+			}
+		}
+		data.alive.forEach(function (cell) { setCell(cell[0], cell[1], true); });
+		turn = data.turn;
+		state = data.state;
+		origin = data.origin;
+		break;
+	case "diff":
+		data.cells.forEach(function (cell) { flipCell(cell[0], cell[1]); });
+		turn = data.turn;
+		break;
+	case "StateChange":
+		state = data.state;
+		break;
+	case "AliveCellsCount":
+		message = "Alive Cells " + data.cellsCount;
+		break;
+	case "ImageOutputComplete":
+		message = "File " + data.filename + " output complete";
+		break;
+	case "SpeedChange":
+		message = data.turnsPerSecond === 0 ? "Speed unlimited" : "Speed " + data.turnsPerSecond + " turns/s";
+		break;
+	}
+	if (!drawing) { // Draw at most once a frame however quickly messages arrive
+		drawing = true;
+		requestAnimationFrame(draw);
+	}
+};
+socket.onclose = function () {
+	state = "Disconnected";
+	showStatus();
+};
+document.querySelectorAll("button").forEach(function (button) {
+	button.onclick = function () {
+		socket.send(JSON.stringify({command: button.dataset.command}));
+	};
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/server"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// viewerMessage holds the fields of any message the server streams to a browser.
+type viewerMessage struct {
+	Type   string   `json:"type"`
+	Turn   int      `json:"turn"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Alive  [][2]int `json:"alive"`
+	Cells  [][2]int `json:"cells"`
+	State  string   `json:"state"`
+}
+
+// Returns the status served at /status
+func getStatus(t *testing.T, url string) server.Status {
+	response, err := http.Get(url + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var status server.Status
+	err = json.NewDecoder(response.Body).Decode(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+// TestServer streams a run to a WebSocket client, pauses it from the client, and checks that the world built from
+// the stream matches the run's own world and the status endpoint.
+func TestServer(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4, FlipEvents: gol.FlipBatches}
+	commands := make(chan gol.Command, 10)
+	viewer := server.New(p, commands)
+	bus := gol.NewBus()
+	consumed := make(chan bool)
+	go func() {
+		viewer.Consume(bus.Subscribe(gol.SubscribeOptions{Buffer: 1000}).Events)
+		close(consumed)
+	}()
+	events := make(chan gol.Event)
+	result := make(chan error)
+	go func() {
+		result <- gol.RunCommands(context.Background(), p, events, commands)
+	}()
+	go bus.Forward(events)
+	httpServer := httptest.NewServer(viewer)
+	defer httpServer.Close()
+
+	page, err := http.Get(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(page.Body)
+	page.Body.Close()
+	if !strings.Contains(string(body), "<canvas") {
+		t.Error("expected the viewer page to contain a canvas")
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	world := make(map[util.Cell]bool)
+	turn := -1
+	paused := false
+	pauseSent := false
+	for !paused {
+		var message viewerMessage
+		_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		err = conn.ReadJSON(&message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch message.Type {
+		case "snapshot":
+			if message.Width != 64 || message.Height != 64 {
+				t.Fatalf("expected a 64x64 snapshot, got %vx%v", message.Width, message.Height)
+			}
+			world = make(map[util.Cell]bool)
+			for _, cell := range message.Alive {
+				world[util.Cell{X: cell[0], Y: cell[1]}] = true
+			}
+			turn = message.Turn
+			paused = message.State == "Paused"
+		case "diff":
+			if turn < 0 {
+				t.Fatal("expected a snapshot before the first diff")
+			}
+			for _, cell := range message.Cells {
+				c := util.Cell{X: cell[0], Y: cell[1]}
+				if world[c] {
+					delete(world, c)
+				} else {
+					world[c] = true
+				}
+			}
+			turn = message.Turn
+		case "StateChange":
+			paused = message.State == "Paused"
+		}
+		if turn >= 10 && !pauseSent { // A browser that falls behind may skip turns, so it pauses at the first it sees
+			err = conn.WriteJSON(map[string]string{"command": "pause"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			pauseSent = true
+		}
+	}
+
+	replies := make(chan gol.Reply, 1)
+	commands <- gol.Command{Type: gol.SnapshotCommand, Reply: replies}
+	snapshot := (<-replies).Snapshot
+	if snapshot.CompletedTurns != turn {
+		t.Errorf("expected the run to be paused at turn %v, got %v", turn, snapshot.CompletedTurns)
+	}
+	var alive []util.Cell
+	for cell := range world {
+		alive = append(alive, cell)
+	}
+	assertEqualBoard(t, alive, snapshot.AliveCells(), p)
+	status := getStatus(t, httpServer.URL)
+	if status.Turn != turn || status.AliveCount != len(alive) || status.State != "Paused" || status.Finished {
+		t.Errorf("expected an unfinished run paused at turn %v with %v alive cells, got %+v", turn, len(alive), status)
+	}
+
+	err = conn.WriteJSON(map[string]string{"command": "quit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil { // Read until the server closes the connection at the end of the run
+		var message viewerMessage
+		err = conn.ReadJSON(&message)
+	}
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("expected the connection to be closed normally, got %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	<-consumed
+	if status := getStatus(t, httpServer.URL); !status.Finished || status.State != "Quitting" {
+		t.Errorf("expected the run to have finished, got %+v", status)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestSimulator steps 16x16 and 64x64 images to 1 and 100 turns and compares them with the expected images.
+func TestSimulator(t *testing.T) {
+	for _, size := range []int{16, 64} {
+		p := gol.Params{ImageWidth: size, ImageHeight: size, Threads: 4}
+		initial := util.ReadAliveCells(fmt.Sprintf("images/%vx%v.pgm", size, size), size, size)
+		simulator, err := gol.NewSimulator(p, initial)
+		if err != nil {
+			t.Fatal(err)
+		}
+		completedTurns := 0
+		for _, turns := range []int{1, 100} {
+			p.Turns = turns
+			err = simulator.Step(turns - completedTurns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			completedTurns = turns
+			expectedAlive := util.ReadAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", size, size, turns), size, size)
+			snapshot := simulator.Snapshot()
+			if snapshot.CompletedTurns != turns {
+				t.Errorf("expected %v completed turns, got %v", turns, snapshot.CompletedTurns)
+			}
+			if simulator.AliveCount() != len(expectedAlive) {
+				t.Errorf("expected %v alive cells, got %v", len(expectedAlive), simulator.AliveCount())
+			}
+			assertEqualBoard(t, snapshot.AliveCells(), expectedAlive, p)
+		}
+		simulator.Close()
+		if simulator.Step(1) != gol.ErrClosed {
+			t.Error("expected stepping a closed simulator to fail")
+		}
+	}
+}
+
+// TestSimulatorSetCell places a blinker in an empty world with SetCell and checks that it oscillates.
+func TestSimulatorSetCell(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Threads: 3}
+	simulator, err := gol.NewSimulator(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer simulator.Close()
+	simulator.SetCell(4, 5, true)
+	simulator.SetCell(5, 5, true)
+	simulator.SetCell(6, 5, true)
+	vertical := []util.Cell{{X: 5, Y: 4}, {X: 5, Y: 5}, {X: 5, Y: 6}}
+	horizontal := []util.Cell{{X: 4, Y: 5}, {X: 5, Y: 5}, {X: 6, Y: 5}}
+	for turn := 1; turn <= 4; turn++ {
+		err = simulator.Step(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := vertical
+		if turn%2 == 0 {
+			expected = horizontal
+		}
+		world := simulator.World()
+		var alive []util.Cell
+		for y, row := range world {
+			for x, cell := range row {
+				if cell == 255 {
+					alive = append(alive, util.Cell{X: x, Y: y})
+				}
+			}
+		}
+		assertEqualBoard(t, alive, expected, p)
+	}
+}
+
+// TestSimulatorInvalid checks that NewSimulator rejects the same params as Run, and alive cells outside the world.
+func TestSimulatorInvalid(t *testing.T) {
+	valid := gol.Params{ImageWidth: 16, ImageHeight: 16, Threads: 2}
+	for _, p := range []gol.Params{
+		{ImageWidth: 0, ImageHeight: 16, Threads: 2},
+		{ImageWidth: 16, ImageHeight: 16, Threads: 0},
+		{ImageWidth: 16, ImageHeight: 16, Threads: 2, Kernel: 10},
+		{ImageWidth: 16, ImageHeight: 16, Threads: 2, TileSize: -1},
+	} {
+		if _, err := gol.NewSimulator(p, nil); err == nil {
+			t.Errorf("expected %+v to be rejected", p)
+		}
+	}
+	for _, cell := range []util.Cell{{X: 16, Y: 0}, {X: 0, Y: -1}} {
+		if _, err := gol.NewSimulator(valid, []util.Cell{cell}); err == nil {
+			t.Errorf("expected the alive cell %v to be rejected", cell)
+		}
+	}
+}
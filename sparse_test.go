@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestSparse runs the check images on the sparse backend, checking the image written, the final alive cells and
+// the cells flipped along the way.
+func TestSparse(t *testing.T) {
+	for _, size := range []int{16, 64, 512} {
+		for _, turns := range []int{0, 1, 100} {
+			expected := util.ReadAliveCells(fmt.Sprintf("check/images/%vx%vx%v.pgm", size, size, turns), size, size)
+			for _, threads := range []int{1, 3, 8} {
+				p := gol.Params{ImageWidth: size, ImageHeight: size, Turns: turns, Threads: threads,
+					FlipEvents: gol.FlipBatches, Backend: gol.SparseBackend}
+				t.Run(fmt.Sprintf("%dx%dx%d-%d", size, size, turns, threads), func(t *testing.T) {
+					events := make(chan gol.Event)
+					gol.Run(p, events, nil)
+					var received []gol.Event
+					for event := range events {
+						if final, ok := event.(gol.FinalTurnComplete); ok {
+							assertEqualBoard(t, final.Alive, expected, p)
+						}
+						received = append(received, event)
+					}
+					assertEqualBoard(t, applyFlips(received, p), expected, p)
+					assertEqualBoard(t, util.ReadAliveCells(fmt.Sprintf("out/%vx%vx%v.pgm", size, size, turns),
+						size, size), expected, p)
+				})
+			}
+		}
+	}
+}
+
+// TestSparseWrap moves a glider across the edges of a small world on the sparse backend, checking that it wraps
+// around them like the dense backend.
+func TestSparseWrap(t *testing.T) {
+	glider := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	var expected []util.Cell
+	for _, cell := range glider { // A glider moves one cell down and right every 4 turns
+		expected = append(expected, util.Cell{X: (cell.X + 10) % 8, Y: (cell.Y + 10) % 8})
+	}
+	dir, err := ioutil.TempDir("", "wrap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, backend := range []gol.Backend{gol.DenseBackend, gol.SparseBackend} {
+		p := gol.Params{ImageWidth: 8, ImageHeight: 8, Turns: 40, Threads: 2, InitialCells: glider,
+			Backend: backend, OutputDir: dir}
+		events := make(chan gol.Event)
+		gol.Run(p, events, nil)
+		for event := range events {
+			if final, ok := event.(gol.FinalTurnComplete); ok {
+				assertEqualBoard(t, final.Alive, expected, p)
+			}
+		}
+	}
+}
+
+// TestInfinitePlane sends a glider far beyond the edges of a small image on an infinite plane, checking that it
+// keeps going rather than wrapping around, and that the image follows it on either backend.
+func TestInfinitePlane(t *testing.T) {
+	dir, err := ioutil.TempDir("", "infinite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	glider := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	var expected []util.Cell
+	for _, cell := range glider {
+		expected = append(expected, util.Cell{X: cell.X + 2500, Y: cell.Y + 2500})
+	}
+	for _, backend := range []gol.Backend{gol.DenseBackend, gol.SparseBackend} {
+		p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 10000, Threads: 4, InitialCells: glider,
+			Backend: backend, InfinitePlane: true, OutputDir: dir}
+		events := make(chan gol.Event)
+		gol.Run(p, events, nil)
+		var received []gol.Event
+		var origin util.Cell
+		moves := 0
+		for event := range events {
+			switch e := event.(type) {
+			case gol.ViewMoved:
+				origin = e.Origin
+				moves++
+			case gol.FinalTurnComplete:
+				assertEqualBoard(t, e.Alive, expected, p)
+			}
+			received = append(received, event)
+		}
+		if moves < 2500/8 { // The image moves whenever the glider comes within 2 cells of its edges
+			t.Errorf("expected the image to move at least %v times, got %v", 2500/8, moves)
+		}
+		var inImage []util.Cell
+		for _, cell := range expected {
+			inImage = append(inImage, util.Cell{X: cell.X - origin.X, Y: cell.Y - origin.Y})
+		}
+		assertEqualBoard(t, applyFlips(received, p), inImage, p)
+		assertEqualBoard(t, util.ReadAliveCells(filepath.Join(dir, "16x16x10000.pgm"), 16, 16), inImage, p)
+	}
+}
+
+// BenchmarkSparse compares 100 turns of the busy 512x512 image and of a glider in a mostly empty world 10 times
+// wider on each backend.
+func BenchmarkSparse(b *testing.B) {
+	os.Stdout = nil // Disable all program output apart from benchmark results
+	glider := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	backends := map[string]gol.Backend{"dense": gol.DenseBackend, "sparse": gol.SparseBackend}
+	for _, name := range []string{"dense", "sparse"} {
+		for _, p := range []gol.Params{
+			{ImageWidth: 512, ImageHeight: 512},
+			{ImageWidth: 5120, ImageHeight: 512, InitialCells: glider},
+		} {
+			p.Turns, p.Threads, p.FlipEvents, p.Backend = 100, 8, gol.FlipNone, backends[name]
+			b.Run(fmt.Sprintf("%v-%vx%v", name, p.ImageWidth, p.ImageHeight), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					runToEnd(p)
+				}
+			})
+		}
+	}
+}
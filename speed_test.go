@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestStep pauses a run, steps forward three turns with 'n' and checks that no other turns were performed.
+func TestStep(t *testing.T) {
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100000000, Threads: 4}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	gol.Run(p, events, keyPresses)
+
+	keyPresses <- 'p'
+	pausedAt := -1
+	finalTurns := -1
+	for event := range events {
+		switch e := event.(type) {
+		case gol.StateChange:
+			if e.NewState == gol.Paused {
+				pausedAt = e.CompletedTurns
+				keyPresses <- 'n'
+				keyPresses <- 'n'
+				keyPresses <- 'n'
+			}
+		case gol.TurnComplete:
+			if pausedAt >= 0 && e.CompletedTurns == pausedAt+3 {
+				keyPresses <- 'q'
+			}
+		case gol.FinalTurnComplete:
+			finalTurns = e.CompletedTurns
+		}
+	}
+	if finalTurns != pausedAt+3 {
+		t.Fatalf("paused at turn %v and stepped 3 times, but finished at turn %v", pausedAt, finalTurns)
+	}
+}
+
+// TestSpeed checks that the speed limit slows a run down and that changing it sends a SpeedChange event.
+func TestSpeed(t *testing.T) {
+	p := gol.Params{ImageWidth: 16, ImageHeight: 16, Turns: 10, Threads: 1, TurnsPerSecond: 10}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 10)
+	start := time.Now()
+	gol.Run(p, events, keyPresses)
+	keyPresses <- '+'
+
+	var speeds []int
+	for event := range events {
+		switch e := event.(type) {
+		case gol.SpeedChange:
+			speeds = append(speeds, e.TurnsPerSecond)
+		}
+	}
+	elapsed := time.Since(start)
+	if len(speeds) != 1 || speeds[0] != 20 {
+		t.Errorf("expected a single SpeedChange to 20 turns/s, got %v", speeds)
+	}
+	if elapsed < 400*time.Millisecond {
+		t.Errorf("expected 10 turns at 10-20 turns/s to take at least 400ms, took %v", elapsed)
+	}
+}
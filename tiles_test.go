@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Returns a random world of the given size with about a third of its cells alive
+func randomCells(width, height int, seed int64) []util.Cell {
+	random := rand.New(rand.NewSource(seed))
+	var cells []util.Cell
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if random.Intn(3) == 0 {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+// TestTiles runs the check images split into tiles of several sizes, including tiles that do not divide the world
+// evenly and a single tile bigger than it, checking the final alive cells and the cells flipped along the way.
+func TestTiles(t *testing.T) {
+	for _, size := range []int{16, 64, 512} {
+		expected := util.ReadAliveCells(fmt.Sprintf("check/images/%vx%vx100.pgm", size, size), size, size)
+		for _, tileSize := range []int{1, 5, 16, 600} {
+			if tileSize == 1 && size > 16 {
+				continue // Too slow to be worth it
+			}
+			for _, threads := range []int{1, 6} {
+				p := gol.Params{ImageWidth: size, ImageHeight: size, Turns: 100, Threads: threads,
+					FlipEvents: gol.FlipBatches, TileSize: tileSize}
+				t.Run(fmt.Sprintf("%dx%d-%d-%d", size, size, tileSize, threads), func(t *testing.T) {
+					assertRun(t, p, expected)
+				})
+			}
+		}
+	}
+}
+
+// TestTilesNotSquare runs random worlds that are not square in tiles with each kernel, checking them against the
+// sparse backend, and with more workers than there are rows or tiles.
+func TestTilesNotSquare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, size := range [][2]int{{40, 8}, {8, 40}, {33, 17}, {1, 9}, {9, 1}, {2, 3}} {
+		p := gol.Params{ImageWidth: size[0], ImageHeight: size[1], Turns: 50, Threads: 64,
+			InitialCells: randomCells(size[0], size[1], int64(i)), OutputDir: dir, Backend: gol.SparseBackend}
+		expected := finalAlive(p)
+		for name, kernel := range kernels {
+			p.Backend, p.Kernel, p.TileSize = gol.DenseBackend, kernel, 4
+			t.Run(fmt.Sprintf("%v-%dx%d", name, size[0], size[1]), func(t *testing.T) {
+				assertRun(t, p, expected)
+			})
+		}
+	}
+}
+
+// BenchmarkTiles compares 100 turns of a random world much wider than it is high split into strips and into tiles
+// of several sizes.
+func BenchmarkTiles(b *testing.B) {
+	os.Stdout = nil // Disable all program output apart from benchmark results
+	cells := randomCells(8192, 64, 1)
+	for _, tileSize := range []int{0, 16, 64, 256} {
+		p := gol.Params{ImageWidth: 8192, ImageHeight: 64, Turns: 100, Threads: 8, FlipEvents: gol.FlipNone,
+			InitialCells: cells, TileSize: tileSize}
+		name := fmt.Sprintf("tiles-%d", tileSize)
+		if tileSize == 0 {
+			name = "strips"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				runToEnd(p)
+			}
+		})
+	}
+}
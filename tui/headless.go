@@ -0,0 +1,37 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// Headless consumes events until the channel is closed without any display, printing progress at most once a second.
+func Headless(p gol.Params, events <-chan gol.Event) {
+	WriteProgress(os.Stdout, p, events, time.Second)
+}
+
+// WriteProgress consumes events until the channel is closed, writing every event with something to say to w along
+// with the progress through the turns at most once every interval.
+func WriteProgress(w io.Writer, p gol.Params, events <-chan gol.Event, interval time.Duration) {
+	lastProgress := time.Now()
+	lastTurns := 0
+	for event := range events {
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			if elapsed := time.Since(lastProgress); elapsed >= interval {
+				turnsPerSecond := float64(e.CompletedTurns-lastTurns) / elapsed.Seconds()
+				fmt.Fprintf(w, "Completed Turns %-8vProgress %v/%v (%.1f turns/s)\n", e.CompletedTurns, e.CompletedTurns,
+					p.Turns, turnsPerSecond)
+				lastProgress = time.Now()
+				lastTurns = e.CompletedTurns
+			}
+		default:
+			if len(event.String()) > 0 {
+				fmt.Fprintf(w, "Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const (
+	clearScreen = "\x1b[2J"
+	cursorHome  = "\x1b[H"
+	clearLine   = "\x1b[K"
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+)
+
+// framePeriod limits how often the board is redrawn so that the terminal is not flooded on fast runs.
+const framePeriod = 100 * time.Millisecond
+
+// Terminal draws a scaled-down view of the world using the same block characters as util.VisualiseMatrix.
+type Terminal struct {
+	w              io.Writer
+	world          [][]bool
+	columns, rows  int
+	completedTurns int
+	status         string
+	lastFrame      time.Time
+}
+
+// Runs stty on the controlling terminal and returns its output
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+// Puts the terminal into raw mode and returns a function that restores the previous mode
+func makeRaw() (func(), error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	_, err = stty("raw", "-echo")
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		_, _ = stty(state)
+	}, nil
+}
+
+// Returns the number of columns and rows of the terminal, defaulting to 80x24 if they cannot be found
+func terminalSize() (int, int) {
+	size, err := stty("size")
+	if err == nil {
+		fields := strings.Fields(size)
+		if len(fields) == 2 {
+			rows, rowsErr := strconv.Atoi(fields[0])
+			columns, columnsErr := strconv.Atoi(fields[1])
+			if rowsErr == nil && columnsErr == nil && rows > 1 && columns > 1 {
+				return columns, rows
+			}
+		}
+	}
+	return 80, 24
+}
+
+// Reads single key presses from stdin and forwards the ones the distributor understands until done is closed
+// A read from stdin cannot be interrupted, so it returns at the first key pressed after the run has finished rather
+// than waiting for ever to send it
+func readKeys(keyPresses chan<- rune, done <-chan bool) {
+	buffer := make([]byte, 1)
+	for {
+		_, err := os.Stdin.Read(buffer)
+		if err != nil {
+			return
+		}
+		key := rune(buffer[0])
+		switch key {
+		case 'p', 's', 'q', 'k', 'n', '+', '=', '-', 'm':
+		case 3: // Ctrl-C does not raise an interrupt in raw mode so treat it as a quit
+			key = 'q'
+		default:
+			continue
+		}
+		select {
+		case <-done:
+			return
+		case keyPresses <- key:
+		}
+	}
+}
+
+// NewTerminal returns a Terminal for a world of the given size, sized to fit the current terminal.
+func NewTerminal(width, height int) *Terminal {
+	columns, rows := terminalSize()
+	return NewTerminalWriter(os.Stdout, width, height, columns, rows)
+}
+
+// NewTerminalWriter returns a Terminal for a world of the given size that draws to w, sized to fit a terminal of the
+// given number of columns and rows.
+func NewTerminalWriter(w io.Writer, width, height, columns, rows int) *Terminal {
+	world := make([][]bool, height)
+	for y := range world {
+		world[y] = make([]bool, width)
+	}
+	return &Terminal{w: w, world: world, columns: columns, rows: rows}
+}
+
+// FlipCell inverts the state of the cell at the given coordinates.
+func (t *Terminal) FlipCell(x, y int) {
+	t.world[y][x] = !t.world[y][x]
+}
+
+// ShowCells replaces every cell with the alive cells given, such as those sent when the view moves.
+func (t *Terminal) ShowCells(alive []util.Cell) {
+	for _, row := range t.world {
+		for x := range row {
+			row[x] = false
+		}
+	}
+	for _, cell := range alive {
+		t.world[cell.Y][cell.X] = true
+	}
+}
+
+// Returns the number of world cells along each side of a single block on screen
+func (t *Terminal) scale() int {
+	height := len(t.world)
+	width := 0
+	if height > 0 {
+		width = len(t.world[0])
+	}
+	availableColumns := t.columns / 2 // Each block is drawn with two characters so that it is roughly square
+	availableRows := t.rows - 1       // The last row is used for the status line
+	scale := 1
+	for width > scale*availableColumns || height > scale*availableRows {
+		scale++
+	}
+	return scale
+}
+
+// Returns true if any cell within the block at the given block coordinates is alive
+func (t *Terminal) blockAlive(blockX, blockY, scale int) bool {
+	for y := blockY * scale; y < (blockY+1)*scale && y < len(t.world); y++ {
+		row := t.world[y]
+		for x := blockX * scale; x < (blockX+1)*scale && x < len(row); x++ {
+			if row[x] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RenderFrame redraws the whole board and the status line.
+func (t *Terminal) RenderFrame() {
+	scale := t.scale()
+	var output strings.Builder
+	output.WriteString(cursorHome)
+	blocksHigh := (len(t.world) + scale - 1) / scale
+	blocks := make([]bool, (len(t.world[0])+scale-1)/scale)
+	for blockY := 0; blockY < blocksHigh; blockY++ {
+		for blockX := range blocks {
+			blocks[blockX] = t.blockAlive(blockX, blockY, scale)
+		}
+		output.WriteString(util.BlockRow(blocks) + clearLine + "\r\n")
+	}
+	output.WriteString(fmt.Sprintf("Completed Turns %-8v1:%-4v%v%v", t.completedTurns, scale, t.status, clearLine))
+	_, _ = io.WriteString(t.w, output.String())
+	t.lastFrame = time.Now()
+}
+
+// Start renders events in the terminal until the channel is closed, reading the control keys from stdin in raw mode.
+func Start(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+	restore, err := makeRaw()
+	if err != nil {
+		fmt.Println("Could not put the terminal into raw mode, key presses will need enter:", err)
+		restore = func() {}
+	}
+	done := make(chan bool)
+	defer close(done)
+	go readKeys(keyPresses, done)
+
+	fmt.Print(hideCursor + clearScreen)
+	NewTerminal(p.ImageWidth, p.ImageHeight).Consume(events)
+	fmt.Print(showCursor + "\r\n")
+	restore()
+}
+
+// Consume draws the events until the channel is closed, redrawing the board at most every framePeriod as turns
+// complete, and whenever there is something new for the status line.
+func (t *Terminal) Consume(events <-chan gol.Event) {
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			t.FlipCell(e.Cell.X, e.Cell.Y)
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				t.FlipCell(cell.X, cell.Y)
+			}
+		case gol.ViewMoved:
+			t.ShowCells(e.Alive)
+		case gol.CellsEdited:
+			t.RenderFrame()
+		case gol.TurnComplete:
+			t.completedTurns = e.CompletedTurns
+			if time.Since(t.lastFrame) >= framePeriod {
+				t.RenderFrame()
+			}
+		default:
+			if len(event.String()) > 0 {
+				t.status = event.String()
+				t.RenderFrame()
+			}
+		}
+	}
+	t.RenderFrame()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/tui"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Returns a closed channel holding the given events
+func eventChannel(events ...gol.Event) <-chan gol.Event {
+	c := make(chan gol.Event, len(events))
+	for _, event := range events {
+		c <- event
+	}
+	close(c)
+	return c
+}
+
+// TestHeadlessProgress feeds the headless front end a fixed run, reporting progress on every turn, and checks what it
+// prints.
+func TestHeadlessProgress(t *testing.T) {
+	events := eventChannel(
+		gol.StateChange{CompletedTurns: 0, NewState: gol.Executing},
+		gol.TurnComplete{CompletedTurns: 1},
+		gol.CellFlipped{CompletedTurns: 1, Cell: util.Cell{X: 1, Y: 1}},
+		gol.TurnComplete{CompletedTurns: 2},
+		gol.AliveCellsCount{CompletedTurns: 2, CellsCount: 5},
+		gol.FinalTurnComplete{CompletedTurns: 2},
+		gol.ImageOutputComplete{CompletedTurns: 2, Filename: "16x16x2"},
+		gol.StateChange{CompletedTurns: 2, NewState: gol.Quitting},
+	)
+	var output bytes.Buffer
+	tui.WriteProgress(&output, gol.Params{Turns: 2}, events, 0)
+
+	expected := []string{
+		`Completed Turns 0       Executing`,
+		`Completed Turns 1       Progress 1/2 \(.+ turns/s\)`,
+		`Completed Turns 2       Progress 2/2 \(.+ turns/s\)`,
+		`Completed Turns 2       Alive Cells 5`,
+		`Completed Turns 2       File 16x16x2 output complete`,
+		`Completed Turns 2       Quitting`,
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %v lines, got %q", len(expected), output.String())
+	}
+	for i, line := range lines {
+		if !regexp.MustCompile("^" + expected[i] + "$").MatchString(line) {
+			t.Errorf("expected line %v to match %q, got %q", i+1, expected[i], line)
+		}
+	}
+}
+
+// TestTerminalRender draws a world scaled down to fit a small terminal and checks the last frame written.
+func TestTerminalRender(t *testing.T) {
+	var output bytes.Buffer
+	terminal := tui.NewTerminalWriter(&output, 8, 4, 8, 3) // Blocks of 2x2 cells leave one row for the status
+	terminal.Consume(eventChannel(
+		gol.CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: 0, Y: 0}},
+		gol.CellsFlipped{CompletedTurns: 0, Cells: []util.Cell{{X: 5, Y: 3}, {X: 4, Y: 2}}},
+		gol.TurnComplete{CompletedTurns: 1},
+		gol.AliveCellsCount{CompletedTurns: 1, CellsCount: 3},
+	))
+
+	frames := strings.Split(output.String(), "\x1b[H")
+	expected := "██      \x1b[K\r\n" +
+		"    ██  \x1b[K\r\n" +
+		"Completed Turns 1       1:2   Alive Cells 3\x1b[K"
+	if last := frames[len(frames)-1]; last != expected {
+		t.Errorf("expected the last frame to be\n%q\ngot\n%q", expected, last)
+	}
+}
+
+// TestTerminalKeysAfterRun presses a key after a terminal run has finished, with nothing receiving key presses, and
+// checks that the goroutine reading them stops rather than waiting for ever to send it.
+func TestTerminalKeysAfterRun(t *testing.T) {
+	stdin, stdout := os.Stdin, os.Stdout
+	defer func() {
+		os.Stdin, os.Stdout = stdin, stdout
+	}()
+	keys, pressKeys, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keys.Close()
+	defer pressKeys.Close()
+	os.Stdin, os.Stdout = keys, nil // Without a terminal, raw mode cannot be entered and the default size is used
+
+	before := runtime.NumGoroutine()
+	tui.Start(gol.Params{ImageWidth: 16, ImageHeight: 16}, eventChannel(), make(chan rune))
+	_, err = pressKeys.Write([]byte("p"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNoLeakedGoroutines(t, before)
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ReadPGM reads a binary PGM image with a maximum value of 255, returning a row of bytes for each line of pixels.
+func ReadPGM(r io.Reader) ([][]byte, error) {
+	reader, err := NewPGMReader(r)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]byte, reader.Height)
+	for y := range rows {
+		rows[y], err = reader.ReadRow()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// PGMReader reads a binary PGM image with a maximum value of 255 a row at a time, so that the whole file never has
+// to be held in memory at once.
+type PGMReader struct {
+	Width  int
+	Height int
+	r      *bufio.Reader
+	y      int // The next row to be read
+}
+
+// NewPGMReader reads the header of a binary PGM image, returning a reader for its rows.
+func NewPGMReader(r io.Reader) (*PGMReader, error) {
+	buffered := bufio.NewReader(r)
+	var fields [4]string
+	for i := range fields {
+		field, err := readPGMField(buffered)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = field
+	}
+	if fields[0] != "P5" {
+		return nil, errors.New("pgm: not a binary pgm file")
+	}
+	width, err := strconv.Atoi(fields[1])
+	if err != nil || width <= 0 {
+		return nil, fmt.Errorf("pgm: invalid width %q", fields[1])
+	}
+	height, err := strconv.Atoi(fields[2])
+	if err != nil || height <= 0 {
+		return nil, fmt.Errorf("pgm: invalid height %q", fields[2])
+	}
+	if fields[3] != "255" {
+		return nil, fmt.Errorf("pgm: unsupported maxval %q", fields[3])
+	}
+	return &PGMReader{Width: width, Height: height, r: buffered}, nil
+}
+
+// ReadRow returns the next row of pixels in a new slice, or io.EOF once every row has been read.
+func (p *PGMReader) ReadRow() ([]byte, error) {
+	if p.y == p.Height {
+		return nil, io.EOF
+	}
+	row := make([]byte, p.Width)
+	_, err := io.ReadFull(p.r, row)
+	if err != nil {
+		return nil, fmt.Errorf("pgm: reading row %v: %v", p.y, err)
+	}
+	p.y++
+	return row, nil
+}
+
+// Reads a whitespace separated header field, skipping comments, along with the single whitespace character after it
+func readPGMField(r *bufio.Reader) (string, error) {
+	var field []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", errors.New("pgm: truncated header")
+		}
+		switch {
+		case b == '#' && len(field) == 0:
+			_, err = r.ReadString('\n')
+			if err != nil {
+				return "", errors.New("pgm: truncated header")
+			}
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			if len(field) > 0 {
+				return string(field), nil
+			}
+		default:
+			field = append(field, b)
+		}
+	}
+}
+
+// WritePGM writes rows of bytes as a binary PGM image with a maximum value of 255, in the same layout as the images
+// written by the io goroutine.
+func WritePGM(w io.Writer, rows [][]byte) error {
+	width := 0
+	if len(rows) > 0 {
+		width = len(rows[0])
+	}
+	buffered := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(buffered, "P5\n%v %v\n255\n", width, len(rows))
+	for _, row := range rows {
+		_, _ = buffered.Write(row)
+	}
+	return buffered.Flush()
+}
@@ -0,0 +1,213 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a named set of alive cells, positioned relative to the top left corner of its bounding box.
+type Pattern struct {
+	Name          string
+	Width, Height int
+	Cells         []Cell
+}
+
+// ParseRLE reads a pattern in the run length encoded format used by most Game of Life tools.
+func ParseRLE(r io.Reader) (Pattern, error) {
+	var pattern Pattern
+	var body strings.Builder
+	scanner := bufio.NewScanner(r)
+	headerRead := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#N"):
+			pattern.Name = strings.TrimSpace(line[2:])
+		case strings.HasPrefix(line, "#"): // All other comment lines are ignored
+		case !headerRead:
+			for _, field := range strings.Split(line, ",") {
+				keyValue := strings.SplitN(field, "=", 2)
+				if len(keyValue) != 2 {
+					return pattern, fmt.Errorf("rle: invalid header %q", line)
+				}
+				key, value := strings.TrimSpace(keyValue[0]), strings.TrimSpace(keyValue[1])
+				var err error
+				switch key {
+				case "x":
+					pattern.Width, err = strconv.Atoi(value)
+				case "y":
+					pattern.Height, err = strconv.Atoi(value)
+				}
+				if err != nil {
+					return pattern, fmt.Errorf("rle: invalid header %q", line)
+				}
+			}
+			headerRead = true
+		default:
+			body.WriteString(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return pattern, err
+	}
+	if !headerRead {
+		return pattern, fmt.Errorf("rle: missing header")
+	}
+
+	longestRun := pattern.Width // No run of cells or rows can be longer than the pattern is wide or high
+	if pattern.Height > longestRun {
+		longestRun = pattern.Height
+	}
+	x, y, count := 0, 0, 0
+	for _, char := range body.String() {
+		if char >= '0' && char <= '9' {
+			digit := int(char - '0')
+			if digit > longestRun || count > (longestRun-digit)/10 { // Checked first, so the count cannot overflow
+				return pattern, fmt.Errorf("rle: a run is longer than the %vx%v header", pattern.Width, pattern.Height)
+			}
+			count = count*10 + digit
+			continue
+		}
+		if count == 0 { // A tag without a count appears once
+			count = 1
+		}
+		switch char {
+		case 'b':
+			x += count
+		case '$':
+			x = 0
+			y += count
+		case '!':
+			return pattern, nil
+		default: // 'o' and any other state are treated as alive
+			if count > pattern.Width-x || y >= pattern.Height { // Checked before the cells are added
+				return pattern, fmt.Errorf("rle: the cells do not fit in the %vx%v header", pattern.Width, pattern.Height)
+			}
+			for i := 0; i < count; i++ {
+				pattern.Cells = append(pattern.Cells, Cell{X: x + i, Y: y})
+			}
+			x += count
+		}
+		count = 0
+	}
+	return pattern, nil
+}
+
+// ReadRLE reads a pattern from an RLE file, naming it after the file if it has no name of its own.
+func ReadRLE(path string) (Pattern, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Pattern{}, err
+	}
+	defer file.Close()
+	pattern, err := ParseRLE(file)
+	if err != nil {
+		return pattern, fmt.Errorf("%v: %v", path, err)
+	}
+	if pattern.Name == "" {
+		pattern.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return pattern, nil
+}
+
+// ReadPatternLibrary reads every .rle file in a directory, sorted by file name.
+func ReadPatternLibrary(dir string) ([]Pattern, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.EqualFold(filepath.Ext(file.Name()), ".rle") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	var patterns []Pattern
+	for _, name := range names {
+		pattern, err := ReadRLE(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// rleLineLength is the longest line WriteRLE writes, as recommended by the format.
+const rleLineLength = 70
+
+// WriteRLE writes a pattern in the run length encoded format read by ParseRLE, using the standard B3/S23 rule.
+func WriteRLE(w io.Writer, pattern Pattern) error {
+	alive := make(map[Cell]bool, len(pattern.Cells))
+	for _, cell := range pattern.Cells {
+		alive[cell] = true
+	}
+	var header strings.Builder
+	if pattern.Name != "" {
+		fmt.Fprintf(&header, "#N %v\n", pattern.Name)
+	}
+	fmt.Fprintf(&header, "x = %v, y = %v, rule = B3/S23\n", pattern.Width, pattern.Height)
+	_, err := io.WriteString(w, header.String())
+	if err != nil {
+		return err
+	}
+
+	run := func(count int, tag byte) string {
+		if count == 1 {
+			return string(tag)
+		}
+		return strconv.Itoa(count) + string(tag)
+	}
+	var runs []string
+	y := 0 // The row the runs so far end on
+	for row := 0; row < pattern.Height; row++ {
+		var rowRuns []string
+		count, tag := 0, byte('b')
+		for x := 0; x < pattern.Width; x++ {
+			cellTag := byte('b')
+			if alive[Cell{X: x, Y: row}] {
+				cellTag = 'o'
+			}
+			if cellTag != tag && count > 0 {
+				rowRuns = append(rowRuns, run(count, tag))
+				count = 0
+			}
+			count, tag = count+1, cellTag
+		}
+		if tag == 'o' { // Dead cells at the end of a row are left out
+			rowRuns = append(rowRuns, run(count, tag))
+		}
+		if len(rowRuns) == 0 {
+			continue
+		}
+		if row > y {
+			runs = append(runs, run(row-y, '$'))
+			y = row
+		}
+		runs = append(runs, rowRuns...)
+	}
+	runs = append(runs, "!")
+
+	var body strings.Builder
+	lineLength := 0
+	for _, r := range runs {
+		if lineLength+len(r) > rleLineLength {
+			body.WriteString("\n")
+			lineLength = 0
+		}
+		body.WriteString(r)
+		lineLength += len(r)
+	}
+	body.WriteString("\n")
+	_, err = io.WriteString(w, body.String())
+	return err
+}
@@ -5,10 +5,29 @@ import (
 	"strings"
 )
 
+// AliveBlock and DeadBlock draw a single cell, using two characters so that it is roughly square.
+const (
+	AliveBlock = "██"
+	DeadBlock  = "  "
+)
+
 func VisualiseMatrix(given [][]uint8, width, height int) {
 	fmt.Print(matricesToString(given, nil, width, height))
 }
 
+// BlockRow draws a row of cells the way VisualiseMatrix does, without the border.
+func BlockRow(alive []bool) string {
+	var row strings.Builder
+	for _, cell := range alive {
+		if cell {
+			row.WriteString(AliveBlock)
+		} else {
+			row.WriteString(DeadBlock)
+		}
+	}
+	return row.String()
+}
+
 func (c1 Cell) in(slice []Cell) bool {
 	for _, c2 := range slice {
 		if c1 == c2 {
@@ -80,9 +99,9 @@ func squaresToStrings(given, expected [][]uint8, width, height int) []string {
 		output = append(output, fmt.Sprintf("%2d│", i))
 		for j := 0; j < width; j++ {
 			if given[i][j] == 0xFF {
-				output = append(output, "██")
+				output = append(output, AliveBlock)
 			} else if given [i][j] == 0x00 {
-				output = append(output, "  ")
+				output = append(output, DeadBlock)
 			}
 		}
 
@@ -90,9 +109,9 @@ func squaresToStrings(given, expected [][]uint8, width, height int) []string {
 			output = append(output, fmt.Sprintf("│   %2d│", i))
 			for j := 0; j < width; j++ {
 				if expected[i][j] == 0xFF {
-					output = append(output, "██")
+					output = append(output, AliveBlock)
 				} else if expected[i][j] == 0x00 {
-					output = append(output, "  ")
+					output = append(output, DeadBlock)
 				}
 			}
 		}
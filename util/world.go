@@ -0,0 +1,167 @@
+package util
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// worldMagic starts every world file, followed by worldVersion.
+const worldMagic = "GOLW"
+
+// worldVersion is the version of the world format written by WriteWorld.
+const worldVersion = 1
+
+// maxWorldSide is the largest width or height ReadWorld accepts, so that a corrupt header cannot ask for too much.
+const maxWorldSide = 1 << 20
+
+// WorldHeader describes the world held in a world file.
+type WorldHeader struct {
+	Width, Height int
+	Turn          int    // The number of turns completed to reach the world
+	Rule          string // The rule the world evolves by, such as B3/S23
+}
+
+// ErrWorldChecksum is returned by ReadWorld when the cells do not match the checksum in the header.
+var ErrWorldChecksum = errors.New("world: checksum mismatch")
+
+// Returns the number of bytes a row of the given width is packed into, at 8 cells a byte
+func packedWidth(width int) int {
+	return (width + 7) / 8
+}
+
+// Packs a row of cells, where any value other than 0 is alive, into packed with the first cell in the lowest bit
+func packRow(row []byte, packed []byte) {
+	for i := range packed {
+		packed[i] = 0
+	}
+	for x, cell := range row {
+		if cell != 0 {
+			packed[x/8] |= 1 << uint(x%8)
+		}
+	}
+}
+
+// Unpacks a packed row into row, setting alive cells to 255
+func unpackRow(packed []byte, row []byte) {
+	for x := range row {
+		if packed[x/8]&(1<<uint(x%8)) != 0 {
+			row[x] = 255
+		} else {
+			row[x] = 0
+		}
+	}
+}
+
+// WriteWorld writes a world in the compressed world format, where rows hold a byte for each cell that is 0 when
+// the cell is dead. The cells are packed 8 to a byte and compressed with gzip, after a header holding the
+// dimensions, turn and rule, and a CRC-32 checksum of the packed cells.
+func WriteWorld(w io.Writer, header WorldHeader, rows [][]byte) error {
+	if len(rows) != header.Height {
+		return fmt.Errorf("world: expected %v rows, got %v", header.Height, len(rows))
+	}
+	if len(header.Rule) > 255 {
+		return errors.New("world: rule is too long")
+	}
+	packed := make([]byte, packedWidth(header.Width))
+	checksum := crc32.NewIEEE()
+	for _, row := range rows { // The checksum is needed for the header, before any cells are written
+		if len(row) != header.Width {
+			return fmt.Errorf("world: expected rows of %v cells, got %v", header.Width, len(row))
+		}
+		packRow(row, packed)
+		_, _ = checksum.Write(packed)
+	}
+
+	buffered := bufio.NewWriter(w)
+	_, _ = buffered.WriteString(worldMagic)
+	_ = buffered.WriteByte(worldVersion)
+	fields := []interface{}{
+		uint32(header.Width),
+		uint32(header.Height),
+		uint64(header.Turn),
+		uint8(len(header.Rule)),
+	}
+	for _, field := range fields {
+		_ = binary.Write(buffered, binary.LittleEndian, field)
+	}
+	_, _ = buffered.WriteString(header.Rule)
+	_ = binary.Write(buffered, binary.LittleEndian, checksum.Sum32())
+
+	compressor := gzip.NewWriter(buffered)
+	for _, row := range rows {
+		packRow(row, packed)
+		_, err := compressor.Write(packed)
+		if err != nil {
+			return err
+		}
+	}
+	err := compressor.Close()
+	if err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// ReadWorld reads a world written by WriteWorld, returning its rows with alive cells set to 255.
+func ReadWorld(r io.Reader) (WorldHeader, [][]byte, error) {
+	var header WorldHeader
+	buffered := bufio.NewReader(r)
+	start := make([]byte, len(worldMagic)+1)
+	_, err := io.ReadFull(buffered, start)
+	if err != nil || string(start[:len(worldMagic)]) != worldMagic {
+		return header, nil, errors.New("world: not a world file")
+	}
+	if start[len(worldMagic)] != worldVersion {
+		return header, nil, fmt.Errorf("world: unsupported version %v", start[len(worldMagic)])
+	}
+	var fields struct {
+		Width, Height uint32
+		Turn          uint64
+		RuleLength    uint8
+	}
+	err = binary.Read(buffered, binary.LittleEndian, &fields)
+	if err != nil {
+		return header, nil, err
+	}
+	if fields.Width > maxWorldSide || fields.Height > maxWorldSide {
+		return header, nil, fmt.Errorf("world: %vx%v is too large", fields.Width, fields.Height)
+	}
+	rule := make([]byte, fields.RuleLength)
+	_, err = io.ReadFull(buffered, rule)
+	if err != nil {
+		return header, nil, err
+	}
+	var expected uint32
+	err = binary.Read(buffered, binary.LittleEndian, &expected)
+	if err != nil {
+		return header, nil, err
+	}
+	header = WorldHeader{Width: int(fields.Width), Height: int(fields.Height), Turn: int(fields.Turn), Rule: string(rule)}
+
+	decompressor, err := gzip.NewReader(buffered)
+	if err != nil {
+		return header, nil, err
+	}
+	defer decompressor.Close()
+	packed := make([]byte, packedWidth(header.Width))
+	checksum := crc32.NewIEEE()
+	rows := make([][]byte, header.Height)
+	for y := range rows {
+		_, err = io.ReadFull(decompressor, packed)
+		if err != nil {
+			return header, nil, fmt.Errorf("world: reading row %v: %v", y, err)
+		}
+		_, _ = checksum.Write(packed)
+		rows[y] = make([]byte, header.Width)
+		unpackRow(packed, rows[y])
+	}
+	if checksum.Sum32() != expected {
+		return header, nil, ErrWorldChecksum
+	}
+	return header, rows, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Returns the rows of a PGM image
+func readPGMFile(t *testing.T, path string) [][]byte {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := util.ReadPGM(file)
+	if err != nil {
+		t.Fatalf("%v: %v", path, err)
+	}
+	return rows
+}
+
+// Writes rows to a world file
+func writeWorldFile(t *testing.T, path string, header util.WorldHeader, rows [][]byte) {
+	var buffer bytes.Buffer
+	err := util.WriteWorld(&buffer, header, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, buffer.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWorldRoundTrip converts every check image to the world format and back, and checks that the PGM written is
+// identical to the original.
+func TestWorldRoundTrip(t *testing.T) {
+	paths, err := filepath.Glob("check/images/*.pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("expected check images")
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			original, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows := readPGMFile(t, path)
+			header := util.WorldHeader{Width: len(rows[0]), Height: len(rows), Turn: 100, Rule: "B3/S23"}
+			var world bytes.Buffer
+			err = util.WriteWorld(&world, header, rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if world.Len() >= len(original) {
+				t.Errorf("expected the world file to be smaller than the %v byte PGM, got %v bytes", len(original),
+					world.Len())
+			}
+
+			readHeader, readRows, err := util.ReadWorld(&world)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if readHeader != header {
+				t.Errorf("expected header %+v, got %+v", header, readHeader)
+			}
+			var pgm bytes.Buffer
+			err = util.WritePGM(&pgm, readRows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(pgm.Bytes(), original) {
+				t.Error("expected the PGM converted back from the world file to be identical to the original")
+			}
+		})
+	}
+}
+
+// TestWorldChecksum corrupts the cells of a world file and checks that reading it fails.
+func TestWorldChecksum(t *testing.T) {
+	rows := readPGMFile(t, "check/images/16x16x1.pgm")
+	var world bytes.Buffer
+	err := util.WriteWorld(&world, util.WorldHeader{Width: 16, Height: 16, Turn: 1, Rule: "B3/S23"}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := world.Bytes()
+	// The CRC-32 checksum is the last field of the header, after 4 bytes of magic, the version, the dimensions, the
+	// turn and the rule
+	data[4+1+4+4+8+1+len("B3/S23")] ^= 0xff
+	_, _, err = util.ReadWorld(bytes.NewReader(data))
+	if err != util.ErrWorldChecksum {
+		t.Errorf("expected %v, got %v", util.ErrWorldChecksum, err)
+	}
+
+	_, _, err = util.ReadWorld(bytes.NewReader(data[:20]))
+	if err == nil {
+		t.Error("expected a truncated world file to fail")
+	}
+}
+
+// TestWorldFormat runs 100 turns reading and writing world files rather than PGM images, and checks the result and
+// the header written with it.
+func TestWorldFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rows := readPGMFile(t, "images/64x64.pgm")
+	writeWorldFile(t, filepath.Join(dir, "64x64.world"), util.WorldHeader{Width: 64, Height: 64, Rule: "B3/S23"}, rows)
+
+	p := gol.Params{ImageWidth: 64, ImageHeight: 64, Turns: 100, Threads: 4, FlipEvents: gol.FlipNone,
+		InputDir: dir, OutputDir: dir, ImageFormat: gol.WorldFormat}
+	runToEnd(p)
+
+	file, err := os.Open(filepath.Join(dir, "64x64x100.world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	header, rows, err := util.ReadWorld(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (util.WorldHeader{Width: 64, Height: 64, Turn: 100, Rule: "B3/S23"}); header != expected {
+		t.Errorf("expected header %+v, got %+v", expected, header)
+	}
+	var cells []util.Cell
+	for y, row := range rows {
+		for x, cell := range row {
+			if cell != 0 {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	assertEqualBoard(t, cells, util.ReadAliveCells("check/images/64x64x100.pgm", 64, 64), p)
+}
+
+// TestPGMInput reads PGM images with comments in their header, checking that they load, and images of the wrong size
+// or cut short, checking that the run fails rather than loading them.
+func TestPGMInput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pgm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	blinker := strings.Repeat("\x00", 7) + "\xff" + strings.Repeat("\x00", 4) + "\xff" + strings.Repeat("\x00", 4) +
+		"\xff" + strings.Repeat("\x00", 7) // Standing up in the middle of a 5x5 world
+	for name, test := range map[string]struct {
+		image string
+		valid bool
+	}{
+		"comments":  {"P5\n# A blinker\n5 5\n# Standing up\n255\n" + blinker, true},
+		"too small": {"P5\n5 4\n255\n" + blinker[:20], false},
+		"too short": {"P5\n5 5\n255\n" + blinker[:24], false},
+		"not pgm":   {"P2\n5 5\n255\n" + strings.Repeat("0 ", 25), false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := ioutil.WriteFile(filepath.Join(dir, "5x5.pgm"), []byte(test.image), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := gol.Params{ImageWidth: 5, ImageHeight: 5, Turns: 1, Threads: 1, InputDir: dir, OutputDir: dir}
+			events := make(chan gol.Event)
+			result := make(chan error)
+			go func() {
+				result <- gol.RunContext(context.Background(), p, events, nil)
+			}()
+			var final []util.Cell
+			for event := range events {
+				if e, ok := event.(gol.FinalTurnComplete); ok {
+					final = e.Alive
+				}
+			}
+			err = <-result
+			if !test.valid {
+				if err == nil {
+					t.Error("expected the image to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEqualBoard(t, final, []util.Cell{{X: 1, Y: 2}, {X: 2, Y: 2}, {X: 3, Y: 2}}, p)
+		})
+	}
+}